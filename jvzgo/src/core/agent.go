@@ -0,0 +1,113 @@
+package core
+
+import (
+	"fmt"
+	. "types"
+)
+
+func init() {
+	for sym, fn := range agentNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range agentDocs {
+		Docs[sym] = doc
+	}
+}
+
+// send wraps builtins of the form (f agent fn & args), which queue (apply fn state args) on the agent.
+func send(name string, offload bool) func([]MalType) (MalType, error) {
+	return func(args []MalType) (MalType, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s invalid args: %v", name, args)
+		}
+		agent, err := GetAgent(args[0])
+		if err != nil {
+			return nil, err
+		}
+		fn, err := GetFn(args[1])
+		if err != nil {
+			return nil, err
+		}
+		err = agent.Send(func(val MalType) (MalType, error) {
+			return fn(append([]MalType{val}, args[2:]...))
+		}, offload)
+		if err != nil {
+			return nil, err
+		}
+		return agent, nil
+	}
+}
+
+var agentNS = map[string]MalType{
+	`agent`: func(args []MalType) (MalType, error) {
+		meta, validator, err := refOptions("agent", args)
+		if err != nil {
+			return nil, err
+		}
+		agent := NewAgent(args[0])
+		if meta != nil {
+			agent = agent.WithMeta(meta)
+		}
+		if validator != nil {
+			if err := agent.SetValidator(validator); err != nil {
+				return nil, err
+			}
+		}
+		return agent, nil
+	},
+	`agent?`:   MonoPred(IsAgent),
+	`send`:     send("send", false),
+	`send-off`: send("send-off", true),
+	`await`: func(args []MalType) (MalType, error) {
+		for _, arg := range args {
+			agent, err := GetAgent(arg)
+			if err != nil {
+				return nil, err
+			}
+			if err := agent.Await(); err != nil {
+				return nil, err
+			}
+		}
+		return MalNil{}, nil
+	},
+	`agent-error`: MonoErrFunc(func(a MalType) (MalType, error) {
+		agent, err := GetAgent(a)
+		if err != nil {
+			return nil, err
+		}
+		if err := agent.Failure(); err != nil {
+			return ErrorValue(err), nil
+		}
+		return MalNil{}, nil
+	}),
+	`restart-agent`: func(args []MalType) (MalType, error) {
+		if len(args) != 2 && len(args) != 4 {
+			return nil, fmt.Errorf("restart-agent invalid args: %v", args)
+		}
+		agent, err := GetAgent(args[0])
+		if err != nil {
+			return nil, err
+		}
+		clear := false
+		if len(args) == 4 {
+			if opt, _ := args[2].(MalKeyword); opt.Value != "clear-actions" {
+				return nil, fmt.Errorf("restart-agent invalid option: %v", args[2])
+			}
+			clear = IsTruthy(args[3])
+		}
+		if err := agent.Restart(args[1], clear); err != nil {
+			return nil, err
+		}
+		return args[1], nil
+	},
+}
+
+var agentDocs = map[string]string{
+	`agent`:         "Returns an agent holding a value, taking the options :meta and :validator.",
+	`agent?`:        "Returns true if the argument is an agent.",
+	`send`:          "Queues (f value args...) to set the value of an agent, run on a bounded pool of goroutines.",
+	`send-off`:      "Queues (f value args...) to set the value of an agent, run on its own goroutine for blocking work.",
+	`await`:         "Waits until the actions sent to the agents so far have run. Cannot be called from an agent action.",
+	`agent-error`:   "Returns the error which stopped an agent, or nil.",
+	`restart-agent`: "Restarts a failed agent with a new value, dropping its queued actions if :clear-actions is true.",
+}
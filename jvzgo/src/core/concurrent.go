@@ -0,0 +1,296 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+	. "types"
+)
+
+func init() {
+	for sym, fn := range concurrentNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range concurrentDocs {
+		Docs[sym] = doc
+	}
+}
+
+// IsBlockingRef reports whether deref may block on the value and so accepts a timeout.
+func IsBlockingRef(val MalType) bool {
+	_, ok := val.(*MalFuture)
+	return ok
+}
+
+func derefTimeout(ref MalType, timeout MalType, timeoutVal MalType) (MalType, error) {
+	ms, err := GetInt(timeout)
+	if err != nil {
+		return nil, err
+	}
+	future, err := GetFuture(ref)
+	if err != nil {
+		return nil, err
+	}
+	val, ok, err := future.WaitTimeout(time.Duration(ms.Value) * time.Millisecond)
+	if !ok {
+		return timeoutVal, nil
+	}
+	return val, err
+}
+
+// parallel calls each function on its own goroutine, running at most GOMAXPROCS of them at once, and returns
+// their results in order. The first error by position is returned.
+func parallel(fns []func() (MalType, error)) ([]MalType, error) {
+	results := make([]MalType, len(fns))
+	errs := make([]error, len(fns))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, fn := range fns {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, fn func() (MalType, error)) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = fn()
+		}(i, fn)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func futurePred(f func(*MalFuture) bool) func([]MalType) (MalType, error) {
+	return MonoErrFunc(func(a MalType) (MalType, error) {
+		future, err := GetFuture(a)
+		if err != nil {
+			return nil, err
+		}
+		return MalBool{Value: f(future)}, nil
+	})
+}
+
+var concurrentNS = map[string]MalType{
+	`future-call`: MonoErrFunc(func(a MalType) (MalType, error) {
+		fn, err := GetFn(a)
+		if err != nil {
+			return nil, err
+		}
+		return NewFuture(func() (MalType, error) {
+			return fn([]MalType{})
+		}), nil
+	}),
+	`future?`:           MonoPred(IsFuture),
+	`future-done?`:      futurePred((*MalFuture).IsDone),
+	`future-cancelled?`: futurePred((*MalFuture).IsCancelled),
+	`future-cancel`:     futurePred((*MalFuture).Cancel),
+	`promise`: func(args []MalType) (MalType, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("promise invalid args: %v", args)
+		}
+		return NewPromise(), nil
+	},
+	`promise?`: MonoPred(IsPromise),
+	`deliver`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		promise, err := GetFuture(a1)
+		if err != nil || !promise.IsPromise() {
+			return RaiseTypeError("promise", a1)
+		}
+		if !promise.Deliver(a2) {
+			return MalNil{}, nil
+		}
+		return promise, nil
+	}),
+	`pmap`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		fn, err := GetFn(a1)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := seqSlice(a2)
+		if err != nil {
+			return nil, err
+		}
+		fns := make([]func() (MalType, error), len(vals))
+		for i, val := range vals {
+			val := val
+			fns[i] = func() (MalType, error) {
+				return fn([]MalType{val})
+			}
+		}
+		results, err := parallel(fns)
+		if err != nil {
+			return nil, err
+		}
+		return NewList(results), nil
+	}),
+	`pcalls`: func(args []MalType) (MalType, error) {
+		fns := make([]func() (MalType, error), len(args))
+		for i, arg := range args {
+			fn, err := GetFn(arg)
+			if err != nil {
+				return nil, err
+			}
+			fns[i] = func() (MalType, error) {
+				return fn([]MalType{})
+			}
+		}
+		results, err := parallel(fns)
+		if err != nil {
+			return nil, err
+		}
+		return NewList(results), nil
+	},
+	`chan`: func(args []MalType) (MalType, error) {
+		switch len(args) {
+		case 0:
+			return NewChan(0), nil
+		case 1:
+			size, err := GetInt(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if size.Value < 0 {
+				return nil, fmt.Errorf("chan invalid buffer size: %v", size)
+			}
+			return NewChan(size.Value), nil
+		default:
+			return nil, fmt.Errorf("chan invalid args: %v", args)
+		}
+	},
+	`chan?`: MonoPred(IsChan),
+	`>!!`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		ch, err := GetChan(a1)
+		if err != nil {
+			return nil, err
+		}
+		if IsNil(a2) {
+			return nil, errors.New("cannot put nil on a channel")
+		}
+		return MalBool{Value: ch.Put(a2)}, nil
+	}),
+	`<!!`: MonoErrFunc(func(a MalType) (MalType, error) {
+		ch, err := GetChan(a)
+		if err != nil {
+			return nil, err
+		}
+		return ch.Take(), nil
+	}),
+	`close!`: MonoErrFunc(func(a MalType) (MalType, error) {
+		ch, err := GetChan(a)
+		if err != nil {
+			return nil, err
+		}
+		ch.Close()
+		return MalNil{}, nil
+	}),
+	`closed?`: MonoErrFunc(func(a MalType) (MalType, error) {
+		ch, err := GetChan(a)
+		if err != nil {
+			return nil, err
+		}
+		return MalBool{Value: ch.IsClosed()}, nil
+	}),
+	`timeout`: MonoErrFunc(func(a MalType) (MalType, error) {
+		ms, err := GetInt(a)
+		if err != nil {
+			return nil, err
+		}
+		ch := NewChan(0)
+		time.AfterFunc(time.Duration(ms.Value)*time.Millisecond, ch.Close)
+		return ch, nil
+	}),
+	`alts!!`: func(args []MalType) (MalType, error) {
+		if len(args) != 1 && len(args) != 3 {
+			return nil, fmt.Errorf("alts!! invalid args: %v", args)
+		}
+		ports, err := GetSlice(args[0])
+		if err != nil {
+			return nil, err
+		}
+		ops := make([]ChanOp, len(ports))
+		for i, port := range ports {
+			if put, ok := port.(MalList); ok && len(put.Value) == 2 {
+				ops[i].Val = put.Value[1]
+				port = put.Value[0]
+				if IsNil(ops[i].Val) {
+					return nil, errors.New("cannot put nil on a channel")
+				}
+			}
+			if ops[i].Chan, err = GetChan(port); err != nil {
+				return nil, err
+			}
+		}
+		block := true
+		if len(args) == 3 {
+			if kw, ok := args[1].(MalKeyword); !ok || kw.Value != "default" {
+				return nil, fmt.Errorf("alts!! invalid option: %v", args[1])
+			}
+			block = false
+		}
+		i, val, err := Select(ops, block)
+		if err != nil {
+			return nil, fmt.Errorf("alts!! %w", err)
+		}
+		if i < 0 {
+			return NewVecOf(args[2], MalKeyword{Value: "default"}), nil
+		}
+		return NewVecOf(val, ops[i].Chan), nil
+	},
+	`go-call`: MonoErrFunc(func(a MalType) (MalType, error) {
+		fn, err := GetFn(a)
+		if err != nil {
+			return nil, err
+		}
+		// the returned channel receives the result of the call and is then closed
+		ch := NewChan(1)
+		go func() {
+			defer ch.Close()
+			res, err := fn([]MalType{})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error in go block:", err)
+				return
+			}
+			if !IsNil(res) {
+				ch.Put(res)
+			}
+		}()
+		return ch, nil
+	}),
+	`sleep`: MonoErrFunc(func(a MalType) (MalType, error) {
+		ms, err := GetInt(a)
+		if err != nil {
+			return nil, err
+		}
+		time.Sleep(time.Duration(ms.Value) * time.Millisecond)
+		return MalNil{}, nil
+	}),
+}
+
+var concurrentDocs = map[string]string{
+	`future-call`:       "Calls a function on another goroutine and returns a future for its result.",
+	`future?`:           "Returns true if the argument is a future.",
+	`future-done?`:      "Returns true if a future has finished.",
+	`future-cancelled?`: "Returns true if a future was cancelled.",
+	`future-cancel`:     "Cancels a future which has not finished, returning whether it did.",
+	`promise`:           "Returns a promise, which deref waits on until it is delivered.",
+	`promise?`:          "Returns true if the argument is a promise.",
+	`deliver`:           "Delivers a value to a promise and returns the promise, or nil if it was already delivered.",
+	`pmap`:              "Returns a list of the results of a function applied to each element of a collection in parallel.",
+	`pcalls`:            "Calls the functions in parallel and returns a list of their results.",
+	`chan`:              "Returns a channel, buffering at most the given number of values.",
+	`chan?`:             "Returns true if the argument is a channel.",
+	`>!!`:               "Puts a value on a channel, waiting for room, and returns false if the channel is closed.",
+	`<!!`:               "Takes a value from a channel, waiting for one, and returns nil once it is closed and empty.",
+	`close!`:            "Closes a channel.",
+	`closed?`:           "Returns true if a channel is closed.",
+	`timeout`:           "Returns a channel which closes after a number of milliseconds.",
+	`alts!!`:            "Completes the first ready operation of a vector of channels to take from and [channel value] puts, returning [value channel], or [default :default] at once if given :default.",
+	`go-call`:           "Calls a function on a new goroutine and returns a channel receiving its result.",
+	`sleep`:             "Sleeps for a number of milliseconds.",
+}
@@ -1,16 +1,15 @@
 package core
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"printer"
 	"reader"
 	"strings"
 	"time"
 	. "types"
+	"unicode/utf8"
 )
 
 func MonoFunc(f func(MalType) MalType) func([]MalType) (MalType, error) {
@@ -77,6 +76,32 @@ func intBiPred(f func(int, int) bool) func([]MalType) (MalType, error) {
 	})
 }
 
+// refOptions parses the :meta and :validator options following the initial value given to atom and ref.
+func refOptions(name string, args []MalType) (MalType, func([]MalType) (MalType, error), error) {
+	if len(args)&1 != 1 {
+		return nil, nil, fmt.Errorf("%s invalid args: %v", name, args)
+	}
+	var meta MalType
+	var validator func([]MalType) (MalType, error)
+	for i := 1; i < len(args); i += 2 {
+		switch opt, _ := args[i].(MalKeyword); opt.Value {
+		case "meta":
+			meta = args[i+1]
+		case "validator":
+			fn, err := GetFn(args[i+1])
+			if err != nil {
+				return nil, nil, err
+			}
+			validator = fn
+		default:
+			return nil, nil, fmt.Errorf("%s invalid option: %v", name, args[i])
+		}
+	}
+	return meta, validator, nil
+}
+
+// NS holds the core builtins. It is only written by the init functions of this package, so it can be read from
+// any goroutine afterwards; interpreters copy it into their own environment rather than modifying it.
 var NS = map[string]MalType{
 	`+`: intBiFunc(func(a int, b int) int {
 		return a + b
@@ -94,11 +119,15 @@ var NS = map[string]MalType{
 		return NewList(args), nil
 	},
 	`empty?`: MonoErrFunc(func(a MalType) (MalType, error) {
-		list, err := GetSlice(a)
+		seq, err := getSeq(a)
+		if err != nil {
+			return nil, err
+		}
+		_, _, ok, err := SeqNext(seq)
 		if err != nil {
 			return nil, err
 		}
-		return MalBool{Value: len(list) == 0}, nil
+		return MalBool{Value: !ok}, nil
 	}),
 	`count`: MonoErrFunc(func(a MalType) (MalType, error) {
 		switch arg := a.(type) {
@@ -106,12 +135,22 @@ var NS = map[string]MalType{
 			return MalInt{Value: 0}, nil
 		case MalList:
 			return MalInt{Value: len(arg.Value)}, nil
+		case *MalLazySeq:
+			list, err := arg.ToSlice()
+			if err != nil {
+				return nil, err
+			}
+			return MalInt{Value: len(list)}, nil
+		case MalString:
+			return MalInt{Value: utf8.RuneCountInString(arg.Value)}, nil
+		case MalMap:
+			return MalInt{Value: len(arg.Value)}, nil
 		default:
 			return RaiseTypeError("list", arg)
 		}
 	}),
 	`=`: BiFunc(func(a MalType, b MalType) MalType {
-		return MalBool{Value: equal(a, b)}
+		return MalBool{Value: Equal(a, b)}
 	}),
 	`<`: intBiPred(func(a int, b int) bool {
 		return a < b
@@ -126,6 +165,9 @@ var NS = map[string]MalType{
 		return a >= b
 	}),
 	`pr-str`: func(args []MalType) (MalType, error) {
+		if err := realizeAll(args); err != nil {
+			return nil, err
+		}
 		prints := make([]string, len(args))
 		for i, arg := range args {
 			prints[i] = printer.PrintStr(arg, true)
@@ -133,6 +175,9 @@ var NS = map[string]MalType{
 		return MalString{Value: strings.Join(prints, " ")}, nil
 	},
 	`str`: func(args []MalType) (MalType, error) {
+		if err := realizeAll(args); err != nil {
+			return nil, err
+		}
 		str := strings.Builder{}
 		for _, arg := range args {
 			_, err := str.WriteString(printer.PrintStr(arg, false))
@@ -143,6 +188,9 @@ var NS = map[string]MalType{
 		return MalString{Value: str.String()}, nil
 	},
 	`prn`: func(args []MalType) (MalType, error) {
+		if err := realizeAll(args); err != nil {
+			return nil, err
+		}
 		prints := make([]string, len(args))
 		for i, arg := range args {
 			prints[i] = printer.PrintStr(arg, true)
@@ -151,6 +199,9 @@ var NS = map[string]MalType{
 		return MalNil{}, nil
 	},
 	`println`: func(args []MalType) (MalType, error) {
+		if err := realizeAll(args); err != nil {
+			return nil, err
+		}
 		prints := make([]string, len(args))
 		for i, arg := range args {
 			prints[i] = printer.PrintStr(arg, false)
@@ -166,6 +217,13 @@ var NS = map[string]MalType{
 		return reader.ReadStr(str.Value)
 	}),
 	`slurp`: MonoErrFunc(func(a MalType) (MalType, error) {
+		if s, ok := a.(*MalStream); ok {
+			content, err := s.ReadAll()
+			if err != nil {
+				return nil, err
+			}
+			return MalString{Value: content}, nil
+		}
 		str, err := GetString(a)
 		if err != nil {
 			return nil, err
@@ -176,53 +234,153 @@ var NS = map[string]MalType{
 		}
 		return MalString{Value: string(content)}, nil
 	}),
-	`atom`: MonoFunc(func(a MalType) MalType {
-		return NewAtom(a)
-	}),
+	`atom`: func(args []MalType) (MalType, error) {
+		meta, validator, err := refOptions("atom", args)
+		if err != nil {
+			return nil, err
+		}
+		atom := NewAtom(args[0])
+		if meta != nil {
+			atom = atom.WithMeta(meta)
+		}
+		if validator != nil {
+			if err := atom.SetValidator(validator); err != nil {
+				return nil, err
+			}
+		}
+		return atom, nil
+	},
 	`atom?`: MonoFunc(func(a MalType) MalType {
 		_, ok := a.(*MalAtom)
 		return MalBool{Value: ok}
 	}),
-	`deref`: MonoErrFunc(func(a MalType) (MalType, error) {
-		atom, err := GetAtom(a)
+	`deref`: func(args []MalType) (MalType, error) {
+		if len(args) == 3 && IsBlockingRef(args[0]) {
+			// (deref ref timeout-ms timeout-val)
+			return derefTimeout(args[0], args[1], args[2])
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("deref invalid args: %v", args)
+		}
+		if future, ok := args[0].(*MalFuture); ok {
+			return future.Wait()
+		}
+		if ref, ok := args[0].(*MalRef); ok {
+			return ref.Deref()
+		}
+		if agent, ok := args[0].(*MalAgent); ok {
+			return agent.Value(), nil
+		}
+		if v, ok := args[0].(MalVar); ok {
+			return v.Deref()
+		}
+		atom, err := GetAtom(args[0])
 		if err != nil {
 			return nil, err
 		}
 		return atom.Value(), nil
-	}),
+	},
 	`reset!`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
 		atom, err := GetAtom(a1)
 		if err != nil {
 			return nil, err
 		}
-		atom.SetValue(a2)
+		if _, err := atom.Reset(a2); err != nil {
+			return nil, err
+		}
 		return a2, nil
 	}),
+	`reset-vals!`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		atom, err := GetAtom(a1)
+		if err != nil {
+			return nil, err
+		}
+		old, err := atom.Reset(a2)
+		if err != nil {
+			return nil, err
+		}
+		return NewVecOf(old, a2), nil
+	}),
 	`swap!`: func(args []MalType) (MalType, error) {
-		if len(args) < 2 {
-			return nil, fmt.Errorf("invalid args: %v", args)
+		_, res, err := swap(args)
+		return res, err
+	},
+	`swap-vals!`: func(args []MalType) (MalType, error) {
+		old, res, err := swap(args)
+		if err != nil {
+			return nil, err
+		}
+		return NewVecOf(old, res), nil
+	},
+	`compare-and-set!`: func(args []MalType) (MalType, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("compare-and-set! invalid args: %v", args)
 		}
 		atom, err := GetAtom(args[0])
 		if err != nil {
 			return nil, err
 		}
-		fn, err := GetFn(args[1])
+		ok, err := atom.CompareAndSet(args[1], args[2], Equal)
 		if err != nil {
 			return nil, err
 		}
-		fnArgs := make([]MalType, len(args)-1)
-		fnArgs[0] = atom.Value()
-		if len(args) > 2 {
-			copy(fnArgs[1:], args[2:])
+		return MalBool{Value: ok}, nil
+	},
+	`set-validator!`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		var fn func([]MalType) (MalType, error)
+		var err error
+		if !IsNil(a2) {
+			if fn, err = GetFn(a2); err != nil {
+				return nil, err
+			}
+		}
+		switch ref := a1.(type) {
+		case *MalRef:
+			err = ref.SetValidator(fn)
+		case *MalAgent:
+			err = ref.SetValidator(fn)
+		default:
+			var atom *MalAtom
+			if atom, err = GetAtom(a1); err == nil {
+				err = atom.SetValidator(fn)
+			}
 		}
-		res, err := fn(fnArgs)
 		if err != nil {
 			return nil, err
 		}
-		atom.SetValue(res)
-		return res, nil
+		return MalNil{}, nil
+	}),
+	`add-watch`: func(args []MalType) (MalType, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("add-watch invalid args: %v", args)
+		}
+		atom, err := GetAtom(args[0])
+		if err != nil {
+			return nil, err
+		}
+		fn, err := GetFn(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if err := atom.AddWatch(args[1], fn); err != nil {
+			return nil, err
+		}
+		return atom, nil
 	},
+	`remove-watch`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		atom, err := GetAtom(a1)
+		if err != nil {
+			return nil, err
+		}
+		if err := atom.RemoveWatch(a2); err != nil {
+			return nil, err
+		}
+		return atom, nil
+	}),
 	`cons`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		if IsLazySeq(a2) {
+			return NewLazyCons(a1, a2), nil
+		}
 		tail, err := GetSlice(a2)
 		if err != nil {
 			return nil, err
@@ -244,15 +402,25 @@ var NS = map[string]MalType{
 		return NewList(concat), nil
 	},
 	`nth`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
-		list, err := GetSlice(a1)
+		index, err := GetInt(a2)
 		if err != nil {
 			return nil, err
 		}
-		index, err := GetInt(a2)
+		i := index.Value
+		if seq, ok := a1.(*MalLazySeq); ok {
+			return lazyNth(seq, i)
+		}
+		if str, ok := a1.(MalString); ok {
+			runes := []rune(str.Value)
+			if i < 0 || i >= len(runes) {
+				return nil, fmt.Errorf("index out of ranges: %v", i)
+			}
+			return MalChar{Value: runes[i]}, nil
+		}
+		list, err := GetSlice(a1)
 		if err != nil {
 			return nil, err
 		}
-		i := index.Value
 		if i < 0 || i >= len(list) {
 			return nil, fmt.Errorf("index out of ranges: %v", i)
 		}
@@ -267,6 +435,12 @@ var NS = map[string]MalType{
 				return MalNil{}, nil
 			}
 			return list.Value[0], nil
+		case *MalLazySeq:
+			first, _, _, err := SeqNext(list)
+			if err != nil {
+				return nil, err
+			}
+			return WrapNil(first), nil
 		default:
 			return RaiseTypeError("list", a)
 		}
@@ -280,6 +454,15 @@ var NS = map[string]MalType{
 				return NewListOf(), nil
 			}
 			return NewList(list.Value[1:]), nil
+		case *MalLazySeq:
+			_, rest, ok, err := SeqNext(list)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return NewListOf(), nil
+			}
+			return rest, nil
 		default:
 			return RaiseTypeError("list", a)
 		}
@@ -309,10 +492,15 @@ var NS = map[string]MalType{
 		if err != nil {
 			return nil, err
 		}
-		list, err := GetSlice(a2)
+		seq, err := getSeq(a2)
 		if err != nil {
 			return nil, err
 		}
+		if !IsList(seq) {
+			// mapping over a lazy seq stays lazy so that infinite sequences can be mapped
+			return realizeLike(lazyMap(fn, seq), seq)
+		}
+		list, _ := GetSlice(seq)
 		ret := make([]MalType, len(list))
 		for i, v := range list {
 			res, err := fn([]MalType{v})
@@ -329,12 +517,37 @@ var NS = map[string]MalType{
 	`symbol?`:  MonoPred(IsSymbol),
 	`keyword?`: MonoPred(IsKeyword),
 	`string?`:  MonoPred(IsString),
+	`char?`:    MonoPred(IsChar),
 	`number?`:  MonoPred(IsInt),
 	`fn?`:      MonoPred(IsFn),
 	`macro?`:   MonoPred(IsMacro),
 	`list?`:    MonoPred(IsList),
 	`vector?`:  MonoPred(IsVec),
 	`map?`:     MonoPred(IsMap),
+	`char`: MonoErrFunc(func(a MalType) (MalType, error) {
+		switch a := a.(type) {
+		case MalChar:
+			return a, nil
+		case MalInt:
+			// rejects negative values, values past the last code point and the UTF-16 surrogate halves
+			if a.Value < 0 || a.Value > utf8.MaxRune || !utf8.ValidRune(rune(a.Value)) {
+				return nil, fmt.Errorf("char invalid code point: %d", a.Value)
+			}
+			return MalChar{Value: rune(a.Value)}, nil
+		default:
+			return RaiseTypeError("int", a)
+		}
+	}),
+	`int`: MonoErrFunc(func(a MalType) (MalType, error) {
+		switch a := a.(type) {
+		case MalInt:
+			return a, nil
+		case MalChar:
+			return MalInt{Value: int(a.Value)}, nil
+		default:
+			return RaiseTypeError("char", a)
+		}
+	}),
 	`symbol`: MonoErrFunc(func(a MalType) (MalType, error) {
 		str, err := GetString(a)
 		if err != nil {
@@ -358,7 +571,11 @@ var NS = map[string]MalType{
 		}
 		m := make(map[MalType]MalType)
 		for i := 0; i < len(args); i += 2 {
-			m[args[i]] = args[i+1]
+			key, err := MapKey(args[i])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = args[i+1]
 		}
 		return MalMap{Value: m}, nil
 	},
@@ -372,7 +589,11 @@ var NS = map[string]MalType{
 		}
 		updated := CopyMap(m)
 		for i := 1; i < len(args); i += 2 {
-			updated.Value[args[i]] = args[i+1]
+			key, err := MapKey(args[i])
+			if err != nil {
+				return nil, err
+			}
+			updated.Value[key] = args[i+1]
 		}
 		return updated, nil
 	},
@@ -385,7 +606,11 @@ var NS = map[string]MalType{
 			return nil, err
 		}
 		updated := CopyMap(m)
-		for _, key := range args[1:] {
+		for _, arg := range args[1:] {
+			key, err := MapKey(arg)
+			if err != nil {
+				return nil, err
+			}
 			delete(updated.Value, key)
 		}
 		return updated, nil
@@ -394,11 +619,22 @@ var NS = map[string]MalType{
 		if IsNil(a1) {
 			return MalNil{}, nil
 		}
+		if str, ok := a1.(MalString); ok {
+			runes := []rune(str.Value)
+			if i, ok := a2.(MalInt); ok && i.Value >= 0 && i.Value < len(runes) {
+				return MalChar{Value: runes[i.Value]}, nil
+			}
+			return MalNil{}, nil
+		}
 		m, err := GetMap(a1)
 		if err != nil {
 			return nil, err
 		}
-		if val, ok := m.Value[a2]; ok {
+		key, err := MapKey(a2)
+		if err != nil {
+			return nil, err
+		}
+		if val, ok := m.Value[key]; ok {
 			return val, nil
 		}
 		return MalNil{}, nil
@@ -408,7 +644,11 @@ var NS = map[string]MalType{
 		if err != nil {
 			return nil, err
 		}
-		_, ok := m.Value[a2]
+		key, err := MapKey(a2)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := m.Value[key]
 		return MalBool{Value: ok}, nil
 	}),
 	`keys`: MonoErrFunc(func(a MalType) (MalType, error) {
@@ -418,7 +658,7 @@ var NS = map[string]MalType{
 		}
 		keys := make([]MalType, 0, len(m.Value))
 		for key := range m.Value {
-			keys = append(keys, key)
+			keys = append(keys, KeyValue(key))
 		}
 		return NewList(keys), nil
 	}),
@@ -434,8 +674,12 @@ var NS = map[string]MalType{
 		return NewList(vals), nil
 	}),
 	`sequential?`: MonoPred(func(a MalType) bool {
-		_, ok := a.(MalList)
-		return ok
+		switch a.(type) {
+		case MalList, *MalLazySeq:
+			return true
+		default:
+			return false
+		}
 	}),
 	`readline`: MonoErrFunc(func(a MalType) (MalType, error) {
 		prompt, err := GetString(a)
@@ -443,15 +687,11 @@ var NS = map[string]MalType{
 			return nil, err
 		}
 		fmt.Print(prompt.Value)
-		in := bufio.NewScanner(os.Stdin)
-		if in.Scan() {
-			line := strings.TrimSpace(in.Text())
-			return MalString{Value: line}, nil
+		line, ok, err := Stdin.ReadLine()
+		if err != nil || !ok {
+			return MalNil{}, err
 		}
-		if in.Err() != nil {
-			return nil, in.Err()
-		}
-		return MalNil{}, nil
+		return MalString{Value: strings.TrimSpace(line)}, nil
 	}),
 	`meta`:      MonoFunc(GetMeta),
 	`with-meta`: BiErrFunc(WithMeta),
@@ -478,6 +718,12 @@ var NS = map[string]MalType{
 			conj = append(conj, vec...)
 			conj = append(conj, args[1:]...)
 			return NewVec(conj), nil
+		case IsLazySeq(args[0]):
+			var conj MalType = args[0]
+			for _, arg := range args[1:] {
+				conj = NewLazyCons(arg, conj)
+			}
+			return conj, nil
 		case IsNil(args[0]):
 			return NewList(args[1:]), nil
 		default:
@@ -500,18 +746,21 @@ var NS = map[string]MalType{
 				return MalNil{}, nil
 			}
 			return NewList(list), nil
+		case IsLazySeq(a):
+			_, _, ok, err := SeqNext(a)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return MalNil{}, nil
+			}
+			return a, nil
 		case IsString(a):
 			str := a.(MalString).Value
 			if len(str) == 0 {
 				return MalNil{}, nil
 			}
-			r := strings.NewReader(str)
-			chars := make([]MalType, 0, len(str))
-			for ch, _, err := r.ReadRune(); err == nil; ch, _, err = r.ReadRune() {
-				char := fmt.Sprintf("%c", ch)
-				chars = append(chars, MalString{Value: char})
-			}
-			return NewList(chars), nil
+			return stringSeq(str), nil
 		default:
 			return RaiseTypeError("sequence", a)
 		}
@@ -519,89 +768,103 @@ var NS = map[string]MalType{
 	`type-of`: MonoFunc(func(a MalType) MalType {
 		return MalString{Value: TypeName(a), Meta: a}
 	}),
+	`hash`: MonoFunc(func(a MalType) MalType {
+		return MalInt{Value: int(Hash(a))}
+	}),
 }
 
-func equal(a, b MalType) bool {
-	switch a := a.(type) {
-	case MalList:
-		as := a.Value
-		bs, err := GetSlice(b)
-		if err != nil {
-			return false
-		}
-		if len(as) != len(bs) {
-			return false
-		}
-		for i := range as {
-			if !equal(as[i], bs[i]) {
-				return false
-			}
-		}
-		return true
-
-	case MalMap:
-		if b, ok := b.(MalMap); ok {
-			if len(a.Value) != len(b.Value) {
-				return false
-			}
-			for key, x := range a.Value {
-				y, ok := b.Value[key]
-				if !ok || !equal(x, y) {
-					return false
-				}
-			}
-			return true
-		} else {
-			return false
-		}
-
-	case *MalAtom:
-		if b, ok := b.(*MalAtom); ok {
-			return equal(a.Value(), b.Value())
-		} else {
-			return false
-		}
-
-	case MalSymbol:
-		if b, ok := b.(MalSymbol); ok {
-			return a.Value == b.Value
-		} else {
-			return false
-		}
-
-	case MalString:
-		if b, ok := b.(MalString); ok {
-			return a.Value == b.Value
-		} else {
-			return false
-		}
-
-	case MalKeyword:
-		if b, ok := b.(MalKeyword); ok {
-			return a.Value == b.Value
-		} else {
-			return false
-		}
-
-	case MalInt:
-		if b, ok := b.(MalInt); ok {
-			return a.Value == b.Value
-		} else {
-			return false
-		}
-
-	case MalBool:
-		if b, ok := b.(MalBool); ok {
-			return a.Value == b.Value
-		} else {
-			return false
-		}
-
-	case MalNil:
-		_, ok := b.(MalNil)
-		return ok
+// Docs holds the docstrings of the builtins in NS.
+var Docs = map[string]string{
+	`+`:                "Returns the sum of two numbers.",
+	`-`:                "Subtracts the second number from the first.",
+	`*`:                "Returns the product of two numbers.",
+	`/`:                "Divides the first number by the second, discarding the remainder.",
+	`list`:             "Returns a list of the arguments.",
+	`empty?`:           "Returns true if the collection has no elements.",
+	`count`:            "Returns the number of elements in a collection, or 0 for nil.",
+	`=`:                "Returns true if two values are equal.",
+	`<`:                "Returns true if the first number is less than the second.",
+	`<=`:               "Returns true if the first number is less than or equal to the second.",
+	`>`:                "Returns true if the first number is greater than the second.",
+	`>=`:               "Returns true if the first number is greater than or equal to the second.",
+	`pr-str`:           "Returns the readable printed forms of the arguments, separated by spaces.",
+	`str`:              "Returns the printed forms of the arguments concatenated, with strings as they are.",
+	`prn`:              "Prints the readable forms of the arguments, separated by spaces, and a newline.",
+	`println`:          "Prints the arguments as str does, separated by spaces, and a newline.",
+	`read-string`:      "Reads the first form in a string.",
+	`slurp`:            "Returns the contents of a file or the rest of a stream as a string.",
+	`atom`:             "Returns an atom holding a value, taking the options :meta and :validator.",
+	`atom?`:            "Returns true if the argument is an atom.",
+	`deref`:            "Returns the value of an atom, ref, agent, var, future or promise, waiting for a future or promise at most timeout-ms if given.",
+	`reset!`:           "Sets the value of an atom and returns it.",
+	`reset-vals!`:      "Sets the value of an atom and returns [old new].",
+	`swap!`:            "Sets the value of an atom to (f value args...) and returns it.",
+	`swap-vals!`:       "Sets the value of an atom to (f value args...) and returns [old new].",
+	`compare-and-set!`: "Sets the value of an atom to new if it is old, returning whether it did.",
+	`set-validator!`:   "Sets the function which must return true for every new value of an atom, ref or agent, or removes it given nil.",
+	`add-watch`:        "Adds a function called with (key ref old new) on every change to a reference.",
+	`remove-watch`:     "Removes the watch added to a reference with a key.",
+	`cons`:             "Returns a list of a value followed by the elements of a collection.",
+	`concat`:           "Returns a list of the elements of the collections in turn.",
+	`nth`:              "Returns the element of a collection at an index.",
+	`first`:            "Returns the first element of a collection, or nil.",
+	`rest`:             "Returns a list of the elements of a collection after the first.",
+	`throw`:            "Throws a value, which catch* receives.",
+	`apply`:            "Calls a function with the arguments given followed by the elements of the last one.",
+	`map`:              "Returns a list of the results of a function applied to each element of a collection, lazy if the collection is.",
+	`nil?`:             "Returns true if the argument is nil.",
+	`true?`:            "Returns true if the argument is true.",
+	`false?`:           "Returns true if the argument is false.",
+	`symbol?`:          "Returns true if the argument is a symbol.",
+	`keyword?`:         "Returns true if the argument is a keyword.",
+	`string?`:          "Returns true if the argument is a string.",
+	`char?`:            "Returns true if the argument is a character.",
+	`number?`:          "Returns true if the argument is a number.",
+	`fn?`:              "Returns true if the argument is a function and not a macro.",
+	`macro?`:           "Returns true if the argument is a macro.",
+	`list?`:            "Returns true if the argument is a list.",
+	`vector?`:          "Returns true if the argument is a vector.",
+	`map?`:             "Returns true if the argument is a hash map.",
+	`char`:             "Returns the character with a code point, which must be a valid Unicode scalar value.",
+	`int`:              "Returns the code point of a character, or a number as it is.",
+	`symbol`:           "Returns the symbol with a name.",
+	`keyword`:          "Returns the keyword with a name.",
+	`vector`:           "Returns a vector of the arguments.",
+	`hash-map`:         "Returns a hash map of alternating keys and values.",
+	`assoc`:            "Returns a copy of a hash map with the keys given bound to the values following them.",
+	`dissoc`:           "Returns a copy of a hash map without the keys given.",
+	`get`:              "Returns the value of a key in a hash map, or nil.",
+	`contains?`:        "Returns true if a hash map has a key.",
+	`keys`:             "Returns a list of the keys of a hash map.",
+	`vals`:             "Returns a list of the values of a hash map.",
+	`sequential?`:      "Returns true if the argument is a list, vector or lazy sequence.",
+	`readline`:         "Prints a prompt and returns a line read from standard input, or nil at the end of the input.",
+	`meta`:             "Returns the metadata of a value, or of the binding a var refers to.",
+	`with-meta`:        "Returns a copy of a value with the given metadata.",
+	`time-ms`:          "Returns the current time in milliseconds since the Unix epoch.",
+	`conj`:             "Returns a collection with values added: at the front of a list and at the end of a vector.",
+	`seq`:              "Returns a list of the elements of a collection, or of the characters of a string, or nil when empty.",
+	`type-of`:          "Returns the name of the type of a value.",
+	`hash`:             "Returns a hash of a value which is the same for equal values.",
+}
 
-	default:
-		return a == b
+// swap applies (swap! atom f & args), returning the replaced and the new value.
+func swap(args []MalType) (MalType, MalType, error) {
+	if len(args) < 2 {
+		return nil, nil, fmt.Errorf("invalid args: %v", args)
 	}
+	atom, err := GetAtom(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	fn, err := GetFn(args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return atom.Swap(func(val MalType) (MalType, error) {
+		fnArgs := make([]MalType, len(args)-1)
+		fnArgs[0] = val
+		copy(fnArgs[1:], args[2:])
+		return fn(fnArgs)
+	})
 }
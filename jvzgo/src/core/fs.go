@@ -0,0 +1,245 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	. "types"
+)
+
+func init() {
+	for sym, fn := range fsNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range fsDocs {
+		Docs[sym] = doc
+	}
+}
+
+// fsError converts an error from the os package into a mal error holding a map, so that scripts can catch it and
+// tell why the operation failed. The map has the keys :type (:not-found, :exists, :permission, :bad-pattern or
+// :io), :op, :path and :message.
+func fsError(op, path string, err error) error {
+	kind := "io"
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		kind = "not-found"
+	case errors.Is(err, fs.ErrExist):
+		kind = "exists"
+	case errors.Is(err, fs.ErrPermission):
+		kind = "permission"
+	case errors.Is(err, filepath.ErrBadPattern):
+		kind = "bad-pattern"
+	}
+	return MalError{Value: MalMap{Value: map[MalType]MalType{
+		MalKeyword{Value: "type"}:    MalKeyword{Value: kind},
+		MalKeyword{Value: "op"}:      MalString{Value: op},
+		MalKeyword{Value: "path"}:    MalString{Value: path},
+		MalKeyword{Value: "message"}: MalString{Value: err.Error()},
+	}}}
+}
+
+// pathFunc wraps builtins of the form (f path).
+func pathFunc(f func(string) (MalType, error)) func([]MalType) (MalType, error) {
+	return MonoErrFunc(func(a MalType) (MalType, error) {
+		path, err := GetString(a)
+		if err != nil {
+			return nil, err
+		}
+		return f(path.Value)
+	})
+}
+
+// pathBiFunc wraps builtins of the form (f from to).
+func pathBiFunc(f func(string, string) (MalType, error)) func([]MalType) (MalType, error) {
+	return BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		from, err := GetString(a1)
+		if err != nil {
+			return nil, err
+		}
+		to, err := GetString(a2)
+		if err != nil {
+			return nil, err
+		}
+		return f(from.Value, to.Value)
+	})
+}
+
+func stringVec(strs []string) MalType {
+	vals := make([]MalType, len(strs))
+	for i, str := range strs {
+		vals[i] = MalString{Value: str}
+	}
+	return NewVec(vals)
+}
+
+var (
+	errIsDirectory = errors.New("is a directory")
+	errSameFile    = errors.New("source and destination are the same file")
+)
+
+// copyFile copies the contents and permissions of the file from to the file to. It refuses to copy a directory or
+// a file onto itself, and its errors give the path which failed.
+func copyFile(from, to string) error {
+	fail := func(path string, err error) error {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			path = pathErr.Path
+		}
+		return fsError("copy-file", path, err)
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return fail(from, err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fail(from, err)
+	}
+	if info.IsDir() {
+		return fail(from, &fs.PathError{Op: "copy", Path: from, Err: errIsDirectory})
+	}
+	if dstInfo, err := os.Stat(to); err == nil && os.SameFile(info, dstInfo) {
+		return fail(to, &fs.PathError{Op: "copy", Path: to, Err: errSameFile})
+	}
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fail(to, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fail(to, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fail(to, err)
+	}
+	return nil
+}
+
+var fsNS = map[string]MalType{
+	`file-exists?`: pathFunc(func(path string) (MalType, error) {
+		_, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return MalBool{Value: false}, nil
+		}
+		if err != nil {
+			return nil, fsError("file-exists?", path, err)
+		}
+		return MalBool{Value: true}, nil
+	}),
+	`directory?`: pathFunc(func(path string) (MalType, error) {
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return MalBool{Value: false}, nil
+		}
+		if err != nil {
+			return nil, fsError("directory?", path, err)
+		}
+		return MalBool{Value: info.IsDir()}, nil
+	}),
+	`list-dir`: pathFunc(func(path string) (MalType, error) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fsError("list-dir", path, err)
+		}
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		return stringVec(names), nil
+	}),
+	`glob`: pathFunc(func(pattern string) (MalType, error) {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fsError("glob", pattern, err)
+		}
+		sort.Strings(matches)
+		return stringVec(matches), nil
+	}),
+	`file-info`: pathFunc(func(path string) (MalType, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fsError("file-info", path, err)
+		}
+		return MalMap{Value: map[MalType]MalType{
+			MalKeyword{Value: "name"}:       MalString{Value: info.Name()},
+			MalKeyword{Value: "size"}:       MalInt{Value: int(info.Size())},
+			MalKeyword{Value: "mtime"}:      MalInt{Value: int(info.ModTime().UnixNano() / 1e6)},
+			MalKeyword{Value: "mode"}:       MalString{Value: info.Mode().String()},
+			MalKeyword{Value: "directory?"}: MalBool{Value: info.IsDir()},
+		}}, nil
+	}),
+	`mkdir`: pathFunc(func(path string) (MalType, error) {
+		if err := os.MkdirAll(path, 0777); err != nil {
+			return nil, fsError("mkdir", path, err)
+		}
+		return MalNil{}, nil
+	}),
+	`delete-file`: pathFunc(func(path string) (MalType, error) {
+		if err := os.Remove(path); err != nil {
+			return nil, fsError("delete-file", path, err)
+		}
+		return MalNil{}, nil
+	}),
+	`rename-file`: pathBiFunc(func(from, to string) (MalType, error) {
+		if err := os.Rename(from, to); err != nil {
+			return nil, fsError("rename-file", from, err)
+		}
+		return MalNil{}, nil
+	}),
+	`copy-file`: pathBiFunc(func(from, to string) (MalType, error) {
+		if err := copyFile(from, to); err != nil {
+			return nil, err
+		}
+		return MalNil{}, nil
+	}),
+	`path-join`: func(args []MalType) (MalType, error) {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			part, err := GetString(arg)
+			if err != nil {
+				return nil, err
+			}
+			parts[i] = part.Value
+		}
+		return MalString{Value: filepath.Join(parts...)}, nil
+	},
+	`basename`: pathFunc(func(path string) (MalType, error) {
+		return MalString{Value: filepath.Base(path)}, nil
+	}),
+	`dirname`: pathFunc(func(path string) (MalType, error) {
+		return MalString{Value: filepath.Dir(path)}, nil
+	}),
+	`extension`: pathFunc(func(path string) (MalType, error) {
+		return MalString{Value: strings.TrimPrefix(filepath.Ext(path), ".")}, nil
+	}),
+	`absolute-path`: pathFunc(func(path string) (MalType, error) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fsError("absolute-path", path, err)
+		}
+		return MalString{Value: abs}, nil
+	}),
+}
+
+var fsDocs = map[string]string{
+	`file-exists?`:  "Returns true if a path exists.",
+	`directory?`:    "Returns true if a path is a directory.",
+	`list-dir`:      "Returns a sorted vector of the names in a directory.",
+	`glob`:          "Returns a sorted vector of the paths matching a shell pattern.",
+	`file-info`:     "Returns a hash map of the :name, :size, :mtime, :mode and :directory? of a path.",
+	`mkdir`:         "Makes a directory and any missing parents.",
+	`delete-file`:   "Deletes a file or empty directory.",
+	`rename-file`:   "Renames a path.",
+	`copy-file`:     "Copies a file.",
+	`path-join`:     "Joins path elements with the separator.",
+	`basename`:      "Returns the last element of a path.",
+	`dirname`:       "Returns all but the last element of a path.",
+	`extension`:     "Returns the extension of a path, without the dot.",
+	`absolute-path`: "Returns the absolute form of a path.",
+}
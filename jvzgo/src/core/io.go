@@ -0,0 +1,204 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"printer"
+	. "types"
+)
+
+// The standard streams, bound to *in*, *out* and *err*. All reads of standard input go through Stdin so that
+// input buffered by one reader is not lost to another.
+var (
+	Stdin  = NewReaderStream("stdin", os.Stdin, nil)
+	Stdout = NewWriterStream("stdout", os.Stdout, nil)
+	Stderr = NewWriterStream("stderr", os.Stderr, nil)
+)
+
+func init() {
+	for sym, fn := range ioNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range ioDocs {
+		Docs[sym] = doc
+	}
+}
+
+// appendOption parses the :append option following the path given to spit and writer.
+func appendOption(name string, args []MalType) (bool, error) {
+	if len(args)&1 != 0 {
+		return false, fmt.Errorf("%s invalid args: %v", name, args)
+	}
+	appending := false
+	for i := 0; i < len(args); i += 2 {
+		switch opt, _ := args[i].(MalKeyword); opt.Value {
+		case "append":
+			appending = IsTruthy(args[i+1])
+		default:
+			return false, fmt.Errorf("%s invalid option: %v", name, args[i])
+		}
+	}
+	return appending, nil
+}
+
+// openFile opens a file for writing, truncating it unless appending.
+func openFile(path string, appending bool) (*os.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	return os.OpenFile(path, flags, 0666)
+}
+
+// lineSeq lazily reads the lines of a stream.
+func lineSeq(s *MalStream) MalType {
+	return NewLazySeq(func() (MalType, error) {
+		line, ok, err := s.ReadLine()
+		if err != nil || !ok {
+			return MalNil{}, err
+		}
+		return NewLazyCons(MalString{Value: line}, lineSeq(s)), nil
+	})
+}
+
+var ioNS = map[string]MalType{
+	`reader`: MonoErrFunc(func(a MalType) (MalType, error) {
+		if s, ok := a.(*MalStream); ok {
+			return s, nil
+		}
+		path, err := GetString(a)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(path.Value)
+		if err != nil {
+			return nil, err
+		}
+		return NewReaderStream(path.Value, file, file), nil
+	}),
+	`writer`: func(args []MalType) (MalType, error) {
+		if len(args) < 1 {
+			return nil, fmt.Errorf("writer invalid args: %v", args)
+		}
+		if s, ok := args[0].(*MalStream); ok && len(args) == 1 {
+			return s, nil
+		}
+		path, err := GetString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		appending, err := appendOption("writer", args[1:])
+		if err != nil {
+			return nil, err
+		}
+		file, err := openFile(path.Value, appending)
+		if err != nil {
+			return nil, err
+		}
+		return NewWriterStream(path.Value, file, file), nil
+	},
+	`stream?`: MonoPred(IsStream),
+	`spit`: func(args []MalType) (MalType, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("spit invalid args: %v", args)
+		}
+		path, err := GetString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		appending, err := appendOption("spit", args[2:])
+		if err != nil {
+			return nil, err
+		}
+		if err := realizeAll(args[1:2]); err != nil {
+			return nil, err
+		}
+		file, err := openFile(path.Value, appending)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.WriteString(printer.PrintStr(args[1], false)); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return MalNil{}, file.Close()
+	},
+	`read-line`: func(args []MalType) (MalType, error) {
+		s := Stdin
+		switch len(args) {
+		case 0:
+		case 1:
+			var err error
+			if s, err = GetStream(args[0]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("read-line invalid args: %v", args)
+		}
+		line, ok, err := s.ReadLine()
+		if err != nil || !ok {
+			return MalNil{}, err
+		}
+		return MalString{Value: line}, nil
+	},
+	`line-seq`: MonoErrFunc(func(a MalType) (MalType, error) {
+		s, err := GetStream(a)
+		if err != nil {
+			return nil, err
+		}
+		return lineSeq(s), nil
+	}),
+	`write`: func(args []MalType) (MalType, error) {
+		if len(args) < 1 {
+			return nil, fmt.Errorf("write invalid args: %v", args)
+		}
+		s, err := GetStream(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := realizeAll(args[1:]); err != nil {
+			return nil, err
+		}
+		for _, arg := range args[1:] {
+			if err := s.Write(printer.PrintStr(arg, false)); err != nil {
+				return nil, err
+			}
+		}
+		return MalNil{}, nil
+	},
+	`close`: MonoErrFunc(func(a MalType) (MalType, error) {
+		s, err := GetStream(a)
+		if err != nil {
+			return nil, err
+		}
+		return MalNil{}, s.Close()
+	}),
+	`with-open-call`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		s, err := GetStream(a1)
+		if err != nil {
+			return nil, err
+		}
+		fn, err := GetFn(a2)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		res, err := fn([]MalType{s})
+		if closeErr := s.Close(); err == nil {
+			err = closeErr
+		}
+		return res, err
+	}),
+}
+
+var ioDocs = map[string]string{
+	`reader`:         "Returns a stream reading a file.",
+	`writer`:         "Returns a stream writing a file, appending to it with :append true.",
+	`stream?`:        "Returns true if the argument is a stream.",
+	`spit`:           "Writes a string to a file, appending to it with :append true.",
+	`read-line`:      "Returns the next line from a stream, or nil at the end.",
+	`line-seq`:       "Returns a lazy sequence of the lines of a stream.",
+	`write`:          "Writes a string to a stream.",
+	`close`:          "Closes a stream.",
+	`with-open-call`: "Calls a function with a stream and closes the stream afterwards.",
+}
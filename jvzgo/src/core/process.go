@@ -0,0 +1,183 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"printer"
+	"strings"
+	"time"
+	. "types"
+)
+
+func init() {
+	for sym, fn := range processNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range processDocs {
+		Docs[sym] = doc
+	}
+}
+
+// command builds the command given to sh and process: a program name and its arguments as strings, followed by
+// the options :dir, a working directory, :env, a map of variables added to the current environment, and :timeout,
+// in milliseconds, after which the command and the processes it started are killed. sh also takes :in, a string
+// given to the command as its standard input, which is returned separately.
+func command(name string, args []MalType) (*exec.Cmd, context.Context, context.CancelFunc, MalType, error) {
+	all := args
+	var argv []string
+	for len(args) > 0 {
+		str, ok := args[0].(MalString)
+		if !ok {
+			break
+		}
+		argv = append(argv, str.Value)
+		args = args[1:]
+	}
+	if len(argv) == 0 || len(args)&1 != 0 {
+		return nil, nil, nil, nil, fmt.Errorf("%s invalid args: %v", name, all)
+	}
+	var dir string
+	var env []string
+	var in MalType
+	var timeout time.Duration
+	for i := 0; i < len(args); i += 2 {
+		switch opt, _ := args[i].(MalKeyword); opt.Value {
+		case "dir":
+			str, err := GetString(args[i+1])
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			dir = str.Value
+		case "env":
+			m, err := GetMap(args[i+1])
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			env = os.Environ()
+			for key, val := range m.Value {
+				env = append(env, printer.PrintStr(KeyValue(key), false)+"="+printer.PrintStr(val, false))
+			}
+		case "timeout":
+			ms, err := GetInt(args[i+1])
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			timeout = time.Duration(ms.Value) * time.Millisecond
+		case "in":
+			if name == "sh" {
+				in = args[i+1]
+				break
+			}
+			fallthrough
+		default:
+			return nil, nil, nil, nil, fmt.Errorf("%s invalid option: %v", name, args[i])
+		}
+	}
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.WaitDelay = ProcessWaitDelay
+	killGroup(cmd)
+	return cmd, ctx, cancel, in, nil
+}
+
+// processStream wraps builtins returning one of the streams of a process.
+func processStream(f func(*MalProcess) *MalStream) func([]MalType) (MalType, error) {
+	return MonoErrFunc(func(a MalType) (MalType, error) {
+		p, err := GetProcess(a)
+		if err != nil {
+			return nil, err
+		}
+		return f(p), nil
+	})
+}
+
+var processNS = map[string]MalType{
+	`sh`: func(args []MalType) (MalType, error) {
+		cmd, ctx, cancel, in, err := command("sh", args)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		if in != nil {
+			if err := realizeAll([]MalType{in}); err != nil {
+				return nil, err
+			}
+			cmd.Stdin = strings.NewReader(printer.PrintStr(in, false))
+		}
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err = cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return nil, fmt.Errorf("%s timed out", cmd.Args[0])
+		case err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay):
+			return nil, err
+		}
+		return MalMap{Value: map[MalType]MalType{
+			MalKeyword{Value: "exit"}: MalInt{Value: cmd.ProcessState.ExitCode()},
+			MalKeyword{Value: "out"}:  MalString{Value: stdout.String()},
+			MalKeyword{Value: "err"}:  MalString{Value: stderr.String()},
+		}}, nil
+	},
+	`process`: func(args []MalType) (MalType, error) {
+		cmd, ctx, cancel, _, err := command("process", args)
+		if err != nil {
+			return nil, err
+		}
+		p, err := StartProcess(ctx, cancel, cmd)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return p, nil
+	},
+	`process?`: MonoPred(IsProcess),
+	`process-in`: processStream(func(p *MalProcess) *MalStream {
+		return p.In
+	}),
+	`process-out`: processStream(func(p *MalProcess) *MalStream {
+		return p.Out
+	}),
+	`process-err`: processStream(func(p *MalProcess) *MalStream {
+		return p.Err
+	}),
+	`process-wait`: MonoErrFunc(func(a MalType) (MalType, error) {
+		p, err := GetProcess(a)
+		if err != nil {
+			return nil, err
+		}
+		exit, err := p.Wait()
+		if err != nil {
+			return nil, err
+		}
+		return MalInt{Value: exit}, nil
+	}),
+	`process-kill`: MonoErrFunc(func(a MalType) (MalType, error) {
+		p, err := GetProcess(a)
+		if err != nil {
+			return nil, err
+		}
+		return MalNil{}, p.Kill()
+	}),
+}
+
+var processDocs = map[string]string{
+	`sh`:           "Runs a command and returns a hash map of its :exit status, :out and :err, taking the options :dir, :env, :timeout and :in.",
+	`process`:      "Starts a command and returns a process whose streams stay open, taking the options :dir, :env and :timeout.",
+	`process?`:     "Returns true if the argument is a process.",
+	`process-in`:   "Returns the stream writing to the standard input of a process.",
+	`process-out`:  "Returns the stream reading the standard output of a process.",
+	`process-err`:  "Returns the stream reading the standard error of a process.",
+	`process-wait`: "Waits for a process to exit and returns its exit status.",
+	`process-kill`: "Kills a process.",
+}
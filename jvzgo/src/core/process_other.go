@@ -0,0 +1,8 @@
+//go:build !unix
+
+package core
+
+import "os/exec"
+
+// killGroup leaves cmd to be killed on its own where process groups are not supported.
+func killGroup(*exec.Cmd) {}
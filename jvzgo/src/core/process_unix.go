@@ -0,0 +1,20 @@
+//go:build unix
+
+package core
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killGroup starts cmd in a process group of its own and makes cancelling it kill the whole group, so that a
+// timeout also ends the processes it started, which may still hold its output open.
+func killGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return err
+		}
+		return nil
+	}
+}
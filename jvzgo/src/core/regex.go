@@ -0,0 +1,122 @@
+package core
+
+import (
+	"regexp"
+	. "types"
+)
+
+func init() {
+	for sym, fn := range regexNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range regexDocs {
+		Docs[sym] = doc
+	}
+}
+
+// matchResult converts submatches into the value returned by the re- builtins: the matched string when the
+// pattern has no groups and a vector of the match followed by its groups otherwise. Unmatched groups are nil.
+func matchResult(str string, loc []int) MalType {
+	if loc == nil {
+		return MalNil{}
+	}
+	if len(loc) == 2 {
+		return MalString{Value: str[loc[0]:loc[1]]}
+	}
+	groups := make([]MalType, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] < 0 {
+			groups[i] = MalNil{}
+		} else {
+			groups[i] = MalString{Value: str[loc[2*i]:loc[2*i+1]]}
+		}
+	}
+	return NewVec(groups)
+}
+
+// regexStrFunc wraps builtins of the form (f re str).
+func regexStrFunc(f func(MalRegex, string) MalType) func([]MalType) (MalType, error) {
+	return BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		re, err := GetRegex(a1)
+		if err != nil {
+			return nil, err
+		}
+		str, err := GetString(a2)
+		if err != nil {
+			return nil, err
+		}
+		return f(re, str.Value), nil
+	})
+}
+
+// regexReplace replaces at most n matches of re in str, or all of them when n is negative. The replacement is
+// either a string which may refer to groups as $1 or ${name}, or a function called with each match.
+func regexReplace(re *regexp.Regexp, str string, replacement MalType, n int) (MalType, error) {
+	var fn func([]MalType) (MalType, error)
+	var template string
+	if s, ok := replacement.(MalString); ok {
+		template = s.Value
+	} else {
+		var err error
+		if fn, err = GetFn(replacement); err != nil {
+			return RaiseTypeError("string or function", replacement)
+		}
+	}
+	out := make([]byte, 0, len(str))
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(str, n) {
+		out = append(out, str[last:loc[0]]...)
+		if fn == nil {
+			out = re.ExpandString(out, template, str, loc)
+		} else {
+			res, err := fn([]MalType{matchResult(str, loc)})
+			if err != nil {
+				return nil, err
+			}
+			rep, err := GetString(res)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, rep.Value...)
+		}
+		last = loc[1]
+	}
+	out = append(out, str[last:]...)
+	return MalString{Value: string(out)}, nil
+}
+
+var regexNS = map[string]MalType{
+	`regex?`: MonoPred(IsRegex),
+	`re-pattern`: MonoErrFunc(func(a MalType) (MalType, error) {
+		if IsRegex(a) {
+			return a, nil
+		}
+		str, err := GetString(a)
+		if err != nil {
+			return nil, err
+		}
+		return NewRegex(str.Value)
+	}),
+	`re-find`: regexStrFunc(func(re MalRegex, str string) MalType {
+		return matchResult(str, re.Value.FindStringSubmatchIndex(str))
+	}),
+	`re-matches`: regexStrFunc(func(re MalRegex, str string) MalType {
+		return matchResult(str, re.Whole().FindStringSubmatchIndex(str))
+	}),
+	`re-seq`: regexStrFunc(func(re MalRegex, str string) MalType {
+		locs := re.Value.FindAllStringSubmatchIndex(str, -1)
+		matches := make([]MalType, len(locs))
+		for i, loc := range locs {
+			matches[i] = matchResult(str, loc)
+		}
+		return NewList(matches)
+	}),
+}
+
+var regexDocs = map[string]string{
+	`regex?`:     "Returns true if the argument is a regex.",
+	`re-pattern`: "Compiles a string into a regex.",
+	`re-find`:    "Returns the first match of a regex in a string, as a vector with the groups if it has any, or nil.",
+	`re-matches`: "Returns the match of a regex against a whole string, as a vector with the groups if it has any, or nil.",
+	`re-seq`:     "Returns a list of the matches of a regex in a string.",
+}
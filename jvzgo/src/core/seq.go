@@ -0,0 +1,929 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+	. "types"
+)
+
+func init() {
+	for sym, fn := range seqNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range seqDocs {
+		Docs[sym] = doc
+	}
+}
+
+// getSeq converts the given collection into a value that can be traversed with SeqNext. Strings become lists
+// of characters and maps become lists of [key value] vectors.
+func getSeq(a MalType) (MalType, error) {
+	switch coll := a.(type) {
+	case MalNil, MalList, *MalLazySeq:
+		return a, nil
+	case MalString:
+		return stringSeq(coll.Value), nil
+	case MalMap:
+		entries := make([]MalType, 0, len(coll.Value))
+		for k, v := range coll.Value {
+			entries = append(entries, NewVecOf(KeyValue(k), v))
+		}
+		return NewList(entries), nil
+	default:
+		return RaiseTypeError("sequence", a)
+	}
+}
+
+func stringSeq(str string) MalList {
+	chars := make([]MalType, 0, len(str))
+	for _, ch := range str {
+		chars = append(chars, MalChar{Value: ch})
+	}
+	return NewList(chars)
+}
+
+// seqSlice realizes the given collection into a slice of its elements as seen by getSeq.
+func seqSlice(coll MalType) ([]MalType, error) {
+	seq, err := getSeq(coll)
+	if err != nil || IsNil(seq) {
+		return nil, err
+	}
+	return GetSlice(seq)
+}
+
+// realizeLike returns seq unrealized if any of the inputs it was computed from is lazy, and as a fully realized
+// list otherwise so that operations on finite collections keep their eager semantics.
+func realizeLike(seq *MalLazySeq, inputs ...MalType) (MalType, error) {
+	for _, input := range inputs {
+		if IsLazySeq(input) {
+			return seq, nil
+		}
+	}
+	list, err := seq.ToSlice()
+	if err != nil {
+		return nil, err
+	}
+	return NewList(list), nil
+}
+
+// compare orders numbers, strings, characters, keywords, symbols, booleans and sequences of those. nil sorts first.
+func compare(a, b MalType) (int, error) {
+	switch {
+	case IsNil(a) && IsNil(b):
+		return 0, nil
+	case IsNil(a):
+		return -1, nil
+	case IsNil(b):
+		return 1, nil
+	}
+	switch a := a.(type) {
+	case MalInt:
+		if b, ok := b.(MalInt); ok {
+			return cmp.Compare(a.Value, b.Value), nil
+		}
+	case MalString:
+		if b, ok := b.(MalString); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	case MalChar:
+		if b, ok := b.(MalChar); ok {
+			return cmp.Compare(a.Value, b.Value), nil
+		}
+	case MalKeyword:
+		if b, ok := b.(MalKeyword); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	case MalSymbol:
+		if b, ok := b.(MalSymbol); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	case MalBool:
+		if b, ok := b.(MalBool); ok {
+			switch {
+			case a.Value == b.Value:
+				return 0, nil
+			case a.Value:
+				return 1, nil
+			default:
+				return -1, nil
+			}
+		}
+	case MalList, *MalLazySeq:
+		as, err := GetSlice(a)
+		if err != nil {
+			return 0, err
+		}
+		if bs, err := GetSlice(b); err == nil {
+			if len(as) != len(bs) {
+				return len(as) - len(bs), nil
+			}
+			for i := range as {
+				if c, err := compare(as[i], bs[i]); err != nil || c != 0 {
+					return c, err
+				}
+			}
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %v to %v", TypeName(a), TypeName(b))
+}
+
+// comparator adapts a mal function to a comparison. The function may return a number like compare or a boolean
+// indicating whether its first argument sorts before its second.
+func comparator(fn func([]MalType) (MalType, error)) func(MalType, MalType) (int, error) {
+	return func(a, b MalType) (int, error) {
+		res, err := fn([]MalType{a, b})
+		if err != nil {
+			return 0, err
+		}
+		switch res := res.(type) {
+		case MalInt:
+			return res.Value, nil
+		case MalBool:
+			if res.Value {
+				return -1, nil
+			}
+			res2, err := fn([]MalType{b, a})
+			if err != nil {
+				return 0, err
+			}
+			if IsTruthy(res2) {
+				return 1, nil
+			}
+			return 0, nil
+		default:
+			return 0, NewTypeError("number or bool", res)
+		}
+	}
+}
+
+// sortSeq stably sorts the given collection by the keys computed from each element.
+func sortSeq(coll MalType, key func([]MalType) (MalType, error), cmp func(MalType, MalType) (int, error)) (MalType, error) {
+	vals, err := seqSlice(coll)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]MalType, len(vals))
+	for i, val := range vals {
+		keys[i] = val
+		if key != nil {
+			if keys[i], err = key([]MalType{val}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	indices := make([]int, len(vals))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var c int
+		c, err = cmp(keys[indices[i]], keys[indices[j]])
+		return c < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]MalType, len(vals))
+	for i, index := range indices {
+		sorted[i] = vals[index]
+	}
+	return NewList(sorted), nil
+}
+
+// realizeAll fully realizes any lazy seqs contained in the given values so that errors raised while
+// computing them are reported instead of printed.
+func realizeAll(vals []MalType) error {
+	for _, val := range vals {
+		switch val := val.(type) {
+		case MalList:
+			if err := realizeAll(val.Value); err != nil {
+				return err
+			}
+		case MalMap:
+			for k, v := range val.Value {
+				if err := realizeAll([]MalType{KeyValue(k), v}); err != nil {
+					return err
+				}
+			}
+		case *MalLazySeq:
+			list, err := val.ToSlice()
+			if err != nil {
+				return err
+			}
+			if err := realizeAll(list); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func lazyNth(seq MalType, i int) (MalType, error) {
+	if i < 0 {
+		return nil, fmt.Errorf("index out of ranges: %v", i)
+	}
+	for n := 0; ; n++ {
+		first, rest, ok, err := SeqNext(seq)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("index out of ranges: %v", i)
+		}
+		if n == i {
+			return first, nil
+		}
+		seq = rest
+	}
+}
+
+func lazyMap(fn func([]MalType) (MalType, error), seq MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		first, rest, ok, err := SeqNext(seq)
+		if err != nil || !ok {
+			return nil, err
+		}
+		res, err := fn([]MalType{first})
+		if err != nil {
+			return nil, err
+		}
+		return NewLazyCons(res, lazyMap(fn, rest)), nil
+	})
+}
+
+func lazyIterate(fn func([]MalType) (MalType, error), x MalType) *MalLazySeq {
+	return NewLazyCons(x, NewLazySeq(func() (MalType, error) {
+		next, err := fn([]MalType{x})
+		if err != nil {
+			return nil, err
+		}
+		return lazyIterate(fn, next), nil
+	}))
+}
+
+func lazyRepeat(x MalType) *MalLazySeq {
+	return NewLazyCons(x, NewLazySeq(func() (MalType, error) {
+		return lazyRepeat(x), nil
+	}))
+}
+
+// lazyCycle returns the elements of rest and then those of seq over and over.
+func lazyCycle(seq, rest MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		first, next, ok, err := SeqNext(rest)
+		if err == nil && !ok {
+			first, next, ok, err = SeqNext(seq)
+		}
+		if err != nil || !ok {
+			return nil, err
+		}
+		return NewLazyCons(first, lazyCycle(seq, next)), nil
+	})
+}
+
+func lazyRange(start, end, step int, bounded bool) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		if bounded && (step > 0 && start >= end || step < 0 && start <= end || step == 0 && start == end) {
+			return nil, nil
+		}
+		return NewLazyCons(MalInt{Value: start}, lazyRange(start+step, end, step, bounded)), nil
+	})
+}
+
+func lazyTake(n int, seq MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		if n <= 0 {
+			return nil, nil
+		}
+		first, rest, ok, err := SeqNext(seq)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return NewLazyCons(first, lazyTake(n-1, rest)), nil
+	})
+}
+
+func lazyDrop(n int, seq MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		for ; n > 0; n-- {
+			_, rest, ok, err := SeqNext(seq)
+			if err != nil || !ok {
+				return nil, err
+			}
+			seq = rest
+		}
+		return seq, nil
+	})
+}
+
+func lazyTakeWhile(pred func([]MalType) (MalType, error), seq MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		first, rest, ok, err := SeqNext(seq)
+		if err != nil || !ok {
+			return nil, err
+		}
+		res, err := pred([]MalType{first})
+		if err != nil {
+			return nil, err
+		}
+		if !IsTruthy(res) {
+			return nil, nil
+		}
+		return NewLazyCons(first, lazyTakeWhile(pred, rest)), nil
+	})
+}
+
+func lazyDropWhile(pred func([]MalType) (MalType, error), seq MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		for {
+			first, rest, ok, err := SeqNext(seq)
+			if err != nil || !ok {
+				return nil, err
+			}
+			res, err := pred([]MalType{first})
+			if err != nil {
+				return nil, err
+			}
+			if !IsTruthy(res) {
+				return seq, nil
+			}
+			seq = rest
+		}
+	})
+}
+
+func lazyFilter(pred func([]MalType) (MalType, error), seq MalType, keep bool) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		for {
+			first, rest, ok, err := SeqNext(seq)
+			if err != nil || !ok {
+				return nil, err
+			}
+			res, err := pred([]MalType{first})
+			if err != nil {
+				return nil, err
+			}
+			if IsTruthy(res) == keep {
+				return NewLazyCons(first, lazyFilter(pred, rest, keep)), nil
+			}
+			seq = rest
+		}
+	})
+}
+
+// lazyConcat lazily concatenates the sequences contained in seqs.
+func lazyConcat(seqs MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		for {
+			first, rest, ok, err := SeqNext(seqs)
+			if err != nil || !ok {
+				return nil, err
+			}
+			inner, err := getSeq(first)
+			if err != nil {
+				return nil, err
+			}
+			if val, next, ok, err := SeqNext(inner); err != nil {
+				return nil, err
+			} else if ok {
+				return NewLazyCons(val, lazyConcat(NewLazyCons(next, rest))), nil
+			}
+			seqs = rest
+		}
+	})
+}
+
+func lazyInterleave(seqs []MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		firsts := make([]MalType, len(seqs))
+		rests := make([]MalType, len(seqs))
+		for i, seq := range seqs {
+			first, rest, ok, err := SeqNext(seq)
+			if err != nil || !ok {
+				return nil, err
+			}
+			firsts[i] = first
+			rests[i] = rest
+		}
+		var tail MalType = lazyInterleave(rests)
+		for i := len(firsts) - 1; i >= 0; i-- {
+			tail = NewLazyCons(firsts[i], tail)
+		}
+		return tail, nil
+	})
+}
+
+// lazyDistinct skips elements that have been seen before. Elements which can be hash-map keys are tracked in a
+// set and the rest are compared with Equal.
+func lazyDistinct(seq MalType, seen map[MalType]bool, seenList []MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+	next:
+		for {
+			first, rest, ok, err := SeqNext(seq)
+			if err != nil || !ok {
+				return nil, err
+			}
+			seq = rest
+			if key, err := MapKey(first); err == nil {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			} else {
+				for _, val := range seenList {
+					if Equal(val, first) {
+						continue next
+					}
+				}
+				seenList = append(seenList, first)
+			}
+			return NewLazyCons(first, lazyDistinct(seq, seen, seenList)), nil
+		}
+	})
+}
+
+// lazyPartition splits seq into lists of n elements, starting a new list every step elements. The final
+// partition is completed from pad when given, and dropped when incomplete otherwise.
+func lazyPartition(n, step int, pad MalType, seq MalType) *MalLazySeq {
+	return NewLazySeq(func() (MalType, error) {
+		part := make([]MalType, 0, n)
+		for rest := seq; len(part) < n; {
+			first, next, ok, err := SeqNext(rest)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			part = append(part, first)
+			rest = next
+		}
+		if len(part) == 0 {
+			return nil, nil
+		}
+		if len(part) < n {
+			if pad == nil {
+				return nil, nil
+			}
+			for rest := pad; len(part) < n; {
+				first, next, ok, err := SeqNext(rest)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					break
+				}
+				part = append(part, first)
+				rest = next
+			}
+			return NewListOf(NewList(part)), nil
+		}
+		return NewLazyCons(NewList(part), lazyPartition(n, step, pad, lazyDrop(step, seq))), nil
+	})
+}
+
+// intSeqFunc wraps builtins of the form (f n coll).
+func intSeqFunc(f func(int, MalType) *MalLazySeq) func([]MalType) (MalType, error) {
+	return BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		n, err := GetInt(a1)
+		if err != nil {
+			return nil, err
+		}
+		seq, err := getSeq(a2)
+		if err != nil {
+			return nil, err
+		}
+		return realizeLike(f(n.Value, seq), seq)
+	})
+}
+
+// predSeqFunc wraps builtins of the form (f pred coll).
+func predSeqFunc(f func(func([]MalType) (MalType, error), MalType) *MalLazySeq) func([]MalType) (MalType, error) {
+	return BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		pred, err := GetFn(a1)
+		if err != nil {
+			return nil, err
+		}
+		seq, err := getSeq(a2)
+		if err != nil {
+			return nil, err
+		}
+		return realizeLike(f(pred, seq), seq)
+	})
+}
+
+var seqNS = map[string]MalType{
+	`lazy-seq*`: MonoErrFunc(func(a MalType) (MalType, error) {
+		fn, err := GetFn(a)
+		if err != nil {
+			return nil, err
+		}
+		return NewLazySeq(func() (MalType, error) {
+			return fn([]MalType{})
+		}), nil
+	}),
+	`lazy-seq?`: MonoPred(IsLazySeq),
+	`iterate`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		fn, err := GetFn(a1)
+		if err != nil {
+			return nil, err
+		}
+		return lazyIterate(fn, a2), nil
+	}),
+	`repeat`: func(args []MalType) (MalType, error) {
+		switch len(args) {
+		case 1:
+			return lazyRepeat(args[0]), nil
+		case 2:
+			n, err := GetInt(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return lazyTake(n.Value, lazyRepeat(args[1])), nil
+		default:
+			return nil, fmt.Errorf("repeat invalid args: %v", args)
+		}
+	},
+	`cycle`: MonoErrFunc(func(a MalType) (MalType, error) {
+		seq, err := getSeq(a)
+		if err != nil {
+			return nil, err
+		}
+		return lazyCycle(seq, seq), nil
+	}),
+	`range`: func(args []MalType) (MalType, error) {
+		if len(args) > 3 {
+			return nil, fmt.Errorf("range invalid args: %v", args)
+		}
+		bounds := []int{0, 0, 1}
+		for i, arg := range args {
+			n, err := GetInt(arg)
+			if err != nil {
+				return nil, err
+			}
+			bounds[i] = n.Value
+		}
+		switch len(args) {
+		case 0:
+			return lazyRange(0, 0, 1, false), nil
+		case 1:
+			return lazyRange(0, bounds[0], 1, true), nil
+		default:
+			return lazyRange(bounds[0], bounds[1], bounds[2], true), nil
+		}
+	},
+	`take`:       intSeqFunc(lazyTake),
+	`drop`:       intSeqFunc(lazyDrop),
+	`take-while`: predSeqFunc(lazyTakeWhile),
+	`drop-while`: predSeqFunc(lazyDropWhile),
+	`filter`: predSeqFunc(func(pred func([]MalType) (MalType, error), seq MalType) *MalLazySeq {
+		return lazyFilter(pred, seq, true)
+	}),
+	`remove`: predSeqFunc(func(pred func([]MalType) (MalType, error), seq MalType) *MalLazySeq {
+		return lazyFilter(pred, seq, false)
+	}),
+	`mapcat`: predSeqFunc(func(fn func([]MalType) (MalType, error), seq MalType) *MalLazySeq {
+		return lazyConcat(lazyMap(fn, seq))
+	}),
+	`distinct`: MonoErrFunc(func(a MalType) (MalType, error) {
+		seq, err := getSeq(a)
+		if err != nil {
+			return nil, err
+		}
+		return realizeLike(lazyDistinct(seq, make(map[MalType]bool), nil), seq)
+	}),
+	`interleave`: func(args []MalType) (MalType, error) {
+		if len(args) == 0 {
+			return NewListOf(), nil
+		}
+		seqs := make([]MalType, len(args))
+		for i, arg := range args {
+			seq, err := getSeq(arg)
+			if err != nil {
+				return nil, err
+			}
+			seqs[i] = seq
+		}
+		return realizeLike(lazyInterleave(seqs), seqs...)
+	},
+	`partition`: func(args []MalType) (MalType, error) {
+		if len(args) < 2 || len(args) > 4 {
+			return nil, fmt.Errorf("partition invalid args: %v", args)
+		}
+		n, err := GetInt(args[0])
+		if err != nil {
+			return nil, err
+		}
+		step := n
+		if len(args) > 2 {
+			if step, err = GetInt(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		if n.Value <= 0 || step.Value <= 0 {
+			return nil, fmt.Errorf("partition invalid size or step: %v", args)
+		}
+		var pad MalType
+		if len(args) == 4 {
+			if pad, err = getSeq(args[2]); err != nil {
+				return nil, err
+			}
+		}
+		seq, err := getSeq(args[len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		return realizeLike(lazyPartition(n.Value, step.Value, pad, seq), seq)
+	},
+	`reduce`: func(args []MalType) (MalType, error) {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("reduce invalid args: %v", args)
+		}
+		fn, err := GetFn(args[0])
+		if err != nil {
+			return nil, err
+		}
+		seq, err := getSeq(args[len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		var acc MalType
+		if len(args) == 3 {
+			acc = args[1]
+		} else {
+			first, rest, ok, err := SeqNext(seq)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return fn([]MalType{})
+			}
+			acc, seq = first, rest
+		}
+		for {
+			first, rest, ok, err := SeqNext(seq)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return acc, nil
+			}
+			if acc, err = fn([]MalType{acc, first}); err != nil {
+				return nil, err
+			}
+			seq = rest
+		}
+	},
+	`some`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		pred, err := GetFn(a1)
+		if err != nil {
+			return nil, err
+		}
+		seq, err := getSeq(a2)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			first, rest, ok, err := SeqNext(seq)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return MalNil{}, nil
+			}
+			res, err := pred([]MalType{first})
+			if err != nil {
+				return nil, err
+			}
+			if IsTruthy(res) {
+				return res, nil
+			}
+			seq = rest
+		}
+	}),
+	`every?`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		pred, err := GetFn(a1)
+		if err != nil {
+			return nil, err
+		}
+		seq, err := getSeq(a2)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			first, rest, ok, err := SeqNext(seq)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return MalTrue, nil
+			}
+			res, err := pred([]MalType{first})
+			if err != nil {
+				return nil, err
+			}
+			if !IsTruthy(res) {
+				return MalFalse, nil
+			}
+			seq = rest
+		}
+	}),
+	`group-by`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		fn, err := GetFn(a1)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := seqSlice(a2)
+		if err != nil {
+			return nil, err
+		}
+		groups := make(map[MalType]MalType)
+		for _, val := range vals {
+			res, err := fn([]MalType{val})
+			if err != nil {
+				return nil, err
+			}
+			key, err := MapKey(res)
+			if err != nil {
+				return nil, err
+			}
+			group, _ := GetSlice(WrapNil(groups[key]))
+			groups[key] = NewVec(append(group[:len(group):len(group)], val))
+		}
+		return MalMap{Value: groups}, nil
+	}),
+	`frequencies`: MonoErrFunc(func(a MalType) (MalType, error) {
+		vals, err := seqSlice(a)
+		if err != nil {
+			return nil, err
+		}
+		counts := make(map[MalType]MalType)
+		for _, val := range vals {
+			key, err := MapKey(val)
+			if err != nil {
+				return nil, err
+			}
+			count, _ := GetInt(WrapNil(counts[key]))
+			counts[key] = MalInt{Value: count.Value + 1}
+		}
+		return MalMap{Value: counts}, nil
+	}),
+	`compare`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		c, err := compare(a1, a2)
+		if err != nil {
+			return nil, err
+		}
+		return MalInt{Value: c}, nil
+	}),
+	`sort`: func(args []MalType) (MalType, error) {
+		switch len(args) {
+		case 1:
+			return sortSeq(args[0], nil, compare)
+		case 2:
+			cmp, err := GetFn(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return sortSeq(args[1], nil, comparator(cmp))
+		default:
+			return nil, fmt.Errorf("sort invalid args: %v", args)
+		}
+	},
+	`sort-by`: func(args []MalType) (MalType, error) {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("sort-by invalid args: %v", args)
+		}
+		key, err := GetFn(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 2 {
+			return sortSeq(args[1], key, compare)
+		}
+		cmp, err := GetFn(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return sortSeq(args[2], key, comparator(cmp))
+	},
+	`zipmap`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		keys, err := getSeq(a1)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := getSeq(a2)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[MalType]MalType)
+		for {
+			key, nextKeys, ok, err := SeqNext(keys)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			val, nextVals, ok, err := SeqNext(vals)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			if key, err = MapKey(key); err != nil {
+				return nil, err
+			}
+			m[key] = val
+			keys, vals = nextKeys, nextVals
+		}
+		return MalMap{Value: m}, nil
+	}),
+	`into`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		vals, err := seqSlice(a2)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case IsVec(a1):
+			vec, _ := GetSlice(a1)
+			into := make([]MalType, 0, len(vec)+len(vals))
+			into = append(into, vec...)
+			into = append(into, vals...)
+			return NewVec(into), nil
+		case IsMap(a1):
+			m, _ := GetMap(a1)
+			into := CopyMap(m)
+			into.Meta = m.Meta
+			for _, val := range vals {
+				entries := []MalType{val}
+				if IsMap(val) {
+					entries, _ = seqSlice(val)
+				}
+				for _, entry := range entries {
+					kv, err := GetVec(entry)
+					if err != nil || len(kv.Value) != 2 {
+						return nil, fmt.Errorf("into expected [key value] entry: %v", entry)
+					}
+					key, err := MapKey(kv.Value[0])
+					if err != nil {
+						return nil, err
+					}
+					into.Value[key] = kv.Value[1]
+				}
+			}
+			return into, nil
+		case IsList(a1), IsNil(a1):
+			list, _ := GetSlice(WrapNil(a1))
+			into := make([]MalType, 0, len(list)+len(vals))
+			for i := len(vals) - 1; i >= 0; i-- {
+				into = append(into, vals[i])
+			}
+			into = append(into, list...)
+			return NewList(into), nil
+		case IsLazySeq(a1):
+			var into MalType = a1
+			for _, val := range vals {
+				into = NewLazyCons(val, into)
+			}
+			return into, nil
+		default:
+			return RaiseTypeError("collection", a1)
+		}
+	}),
+}
+
+var seqDocs = map[string]string{
+	`lazy-seq*`:   "Returns a lazy sequence of the elements of the collection a function returns, calling it on first use.",
+	`lazy-seq?`:   "Returns true if the argument is a lazy sequence.",
+	`iterate`:     "Returns a lazy sequence of x, (f x), (f (f x)) and so on.",
+	`repeat`:      "Returns a lazy sequence repeating a value, n times if given.",
+	`cycle`:       "Returns a lazy sequence repeating the elements of a collection.",
+	`range`:       "Returns a lazy sequence of numbers from start, 0 by default, to end exclusive, or forever, by step.",
+	`take`:        "Returns a list of the first n elements of a collection, lazy if the collection is.",
+	`drop`:        "Returns a list of the elements of a collection after the first n, lazy if the collection is.",
+	`take-while`:  "Returns a list of the elements of a collection while a predicate holds, lazy if the collection is.",
+	`drop-while`:  "Returns a list of the elements of a collection from the first for which a predicate fails, lazy if the collection is.",
+	`filter`:      "Returns a list of the elements of a collection for which a predicate holds, lazy if the collection is.",
+	`remove`:      "Returns a list of the elements of a collection for which a predicate fails, lazy if the collection is.",
+	`mapcat`:      "Returns a list of the elements of the collections a function returns for each element, lazy if the collection is.",
+	`distinct`:    "Returns the elements of a collection without repeats.",
+	`interleave`:  "Returns a list of the first element of each collection, then the second, and so on, lazy if any collection is.",
+	`partition`:   "Returns the elements of a collection in lists of n, starting every step elements and padded from pad if given.",
+	`reduce`:      "Combines the elements of a collection with a function, starting from init or the first element.",
+	`some`:        "Returns the first truthy result of a predicate on the elements of a collection, or nil.",
+	`every?`:      "Returns true if a predicate holds for every element of a collection.",
+	`group-by`:    "Returns a hash map of the results of a function to vectors of the elements giving them.",
+	`frequencies`: "Returns a hash map of the elements of a collection to how often they occur.",
+	`compare`:     "Returns a negative number, zero or a positive number as the first argument is less than, equal to or greater than the second.",
+	`sort`:        "Returns a list of the elements of a collection in order, by compare or a comparator.",
+	`sort-by`:     "Returns a list of the elements of a collection ordered by a key function, by compare or a comparator.",
+	`zipmap`:      "Returns a hash map of keys to the values at the same positions.",
+	`into`:        "Returns a collection with the elements of another added with conj.",
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"fmt"
+	. "types"
+)
+
+func init() {
+	for sym, fn := range stmNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range stmDocs {
+		Docs[sym] = doc
+	}
+}
+
+// refUpdate wraps builtins of the form (f ref fn & args), which update the ref in the current transaction with
+// (apply fn value args).
+func refUpdate(name string, update func(*Transaction, *MalRef, func(MalType) (MalType, error)) (MalType, error)) func([]MalType) (MalType, error) {
+	return func(args []MalType) (MalType, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s invalid args: %v", name, args)
+		}
+		ref, err := GetRef(args[0])
+		if err != nil {
+			return nil, err
+		}
+		fn, err := GetFn(args[1])
+		if err != nil {
+			return nil, err
+		}
+		tx, err := CurrentTransaction()
+		if err != nil {
+			return nil, err
+		}
+		return update(tx, ref, func(val MalType) (MalType, error) {
+			return fn(append([]MalType{val}, args[2:]...))
+		})
+	}
+}
+
+var stmNS = map[string]MalType{
+	`ref`: func(args []MalType) (MalType, error) {
+		meta, validator, err := refOptions("ref", args)
+		if err != nil {
+			return nil, err
+		}
+		ref := NewRef(args[0])
+		if meta != nil {
+			ref = ref.WithMeta(meta)
+		}
+		if validator != nil {
+			if err := ref.SetValidator(validator); err != nil {
+				return nil, err
+			}
+		}
+		return ref, nil
+	},
+	`ref?`: MonoPred(IsRef),
+	`dosync-call`: MonoErrFunc(func(a MalType) (MalType, error) {
+		fn, err := GetFn(a)
+		if err != nil {
+			return nil, err
+		}
+		return RunTransaction(func(*Transaction) (MalType, error) {
+			return fn(nil)
+		})
+	}),
+	`alter`:   refUpdate("alter", (*Transaction).Alter),
+	`commute`: refUpdate("commute", (*Transaction).Commute),
+	`ref-set`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		ref, err := GetRef(a1)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := CurrentTransaction()
+		if err != nil {
+			return nil, err
+		}
+		return tx.Set(ref, a2)
+	}),
+	`ensure`: MonoErrFunc(func(a MalType) (MalType, error) {
+		ref, err := GetRef(a)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := CurrentTransaction()
+		if err != nil {
+			return nil, err
+		}
+		return tx.Ensure(ref)
+	}),
+}
+
+var stmDocs = map[string]string{
+	`ref`:         "Returns a ref holding a value, taking the options :meta and :validator.",
+	`ref?`:        "Returns true if the argument is a ref.",
+	`dosync-call`: "Calls a function in a transaction, retrying it on conflicts, or in the current transaction if one is running.",
+	`alter`:       "Sets the value of a ref to (f value args...) in the current transaction and returns it.",
+	`commute`:     "Sets the value of a ref to (f value args...) at the commit of the current transaction, without conflicting.",
+	`ref-set`:     "Sets the value of a ref in the current transaction.",
+	`ensure`:      "Returns the value of a ref, keeping other transactions from changing it until the current one commits.",
+}
@@ -0,0 +1,282 @@
+package core
+
+import (
+	"fmt"
+	"printer"
+	"strings"
+	. "types"
+	"unicode"
+)
+
+func init() {
+	for sym, fn := range stringsNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range stringsDocs {
+		Docs[sym] = doc
+	}
+}
+
+func stringFunc(f func(string) string) func([]MalType) (MalType, error) {
+	return MonoErrFunc(func(a MalType) (MalType, error) {
+		str, err := GetString(a)
+		if err != nil {
+			return nil, err
+		}
+		return MalString{Value: f(str.Value)}, nil
+	})
+}
+
+func stringBiPred(f func(string, string) bool) func([]MalType) (MalType, error) {
+	return BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		a, err := GetString(a1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := GetString(a2)
+		if err != nil {
+			return nil, err
+		}
+		return MalBool{Value: f(a.Value, b.Value)}, nil
+	})
+}
+
+func getStrings(args []MalType) ([]string, error) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, err := GetString(arg)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = str.Value
+	}
+	return strs, nil
+}
+
+// runeIndex converts a byte offset into str to a rune offset.
+func runeIndex(str string, i int) MalType {
+	if i < 0 {
+		return MalNil{}
+	}
+	return MalInt{Value: len([]rune(str[:i]))}
+}
+
+// byteIndex converts a rune offset into str to a byte offset, clamping it to the string bounds.
+func byteIndex(str string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	for pos := range str {
+		if i == 0 {
+			return pos
+		}
+		i--
+	}
+	return len(str)
+}
+
+// indexOf wraps string searches that take an optional starting rune index.
+func indexOf(name string, search func(string, string, int) int) func([]MalType) (MalType, error) {
+	return func(args []MalType) (MalType, error) {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("%s invalid args: %v", name, args)
+		}
+		strs, err := getStrings(args[:2])
+		if err != nil {
+			return nil, err
+		}
+		from := -1
+		if len(args) == 3 {
+			i, err := GetInt(args[2])
+			if err != nil {
+				return nil, err
+			}
+			from = i.Value
+		}
+		return runeIndex(strs[0], search(strs[0], strs[1], from)), nil
+	}
+}
+
+var stringsNS = map[string]MalType{
+	`subs`: func(args []MalType) (MalType, error) {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("subs invalid args: %v", args)
+		}
+		str, err := GetString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		runes := []rune(str.Value)
+		start, err := GetInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		end := MalInt{Value: len(runes)}
+		if len(args) == 3 {
+			if end, err = GetInt(args[2]); err != nil {
+				return nil, err
+			}
+		}
+		if start.Value < 0 || end.Value > len(runes) || start.Value > end.Value {
+			return nil, fmt.Errorf("subs index out of range: %v", args)
+		}
+		return MalString{Value: string(runes[start.Value:end.Value])}, nil
+	},
+	`string-split`: func(args []MalType) (MalType, error) {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("string-split invalid args: %v", args)
+		}
+		str, err := GetString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		limit := -1
+		if len(args) == 3 {
+			n, err := GetInt(args[2])
+			if err != nil {
+				return nil, err
+			}
+			limit = n.Value
+		}
+		var parts []string
+		if re, ok := args[1].(MalRegex); ok {
+			parts = re.Value.Split(str.Value, limit)
+		} else {
+			sep, err := GetString(args[1])
+			if err != nil {
+				return nil, err
+			}
+			parts = strings.SplitN(str.Value, sep.Value, limit)
+		}
+		split := make([]MalType, len(parts))
+		for i, part := range parts {
+			split[i] = MalString{Value: part}
+		}
+		return NewVec(split), nil
+	},
+	`string-join`: func(args []MalType) (MalType, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("string-join invalid args: %v", args)
+		}
+		sep := MalString{}
+		if len(args) == 2 {
+			var err error
+			if sep, err = GetString(args[0]); err != nil {
+				return nil, err
+			}
+		}
+		vals, err := seqSlice(args[len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		if err := realizeAll(vals); err != nil {
+			return nil, err
+		}
+		strs := make([]string, len(vals))
+		for i, val := range vals {
+			strs[i] = printer.PrintStr(val, false)
+		}
+		return MalString{Value: strings.Join(strs, sep.Value)}, nil
+	},
+	`string-replace`: func(args []MalType) (MalType, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("string-replace invalid args: %v", args)
+		}
+		if re, ok := args[1].(MalRegex); ok {
+			str, err := GetString(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return regexReplace(re.Value, str.Value, args[2], -1)
+		}
+		strs, err := getStrings(args)
+		if err != nil {
+			return nil, err
+		}
+		return MalString{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}, nil
+	},
+	`string-replace-first`: func(args []MalType) (MalType, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("string-replace-first invalid args: %v", args)
+		}
+		if re, ok := args[1].(MalRegex); ok {
+			str, err := GetString(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return regexReplace(re.Value, str.Value, args[2], 1)
+		}
+		strs, err := getStrings(args)
+		if err != nil {
+			return nil, err
+		}
+		return MalString{Value: strings.Replace(strs[0], strs[1], strs[2], 1)}, nil
+	},
+	`string-upper-case`: stringFunc(strings.ToUpper),
+	`string-lower-case`: stringFunc(strings.ToLower),
+	`string-trim`:       stringFunc(strings.TrimSpace),
+	`string-triml`: stringFunc(func(str string) string {
+		return strings.TrimLeftFunc(str, unicode.IsSpace)
+	}),
+	`string-trimr`: stringFunc(func(str string) string {
+		return strings.TrimRightFunc(str, unicode.IsSpace)
+	}),
+	`string-trim-newline`: stringFunc(func(str string) string {
+		return strings.TrimRight(str, "\r\n")
+	}),
+	`string-reverse`: stringFunc(func(str string) string {
+		runes := []rune(str)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes)
+	}),
+	`string-starts-with?`: stringBiPred(strings.HasPrefix),
+	`string-ends-with?`:   stringBiPred(strings.HasSuffix),
+	`string-includes?`:    stringBiPred(strings.Contains),
+	`string-blank?`: MonoErrFunc(func(a MalType) (MalType, error) {
+		if IsNil(a) {
+			return MalTrue, nil
+		}
+		str, err := GetString(a)
+		if err != nil {
+			return nil, err
+		}
+		return MalBool{Value: strings.TrimSpace(str.Value) == ""}, nil
+	}),
+	`string-index-of`: indexOf("string-index-of", func(str, sub string, from int) int {
+		start := byteIndex(str, from)
+		i := strings.Index(str[start:], sub)
+		if i < 0 {
+			return i
+		}
+		return start + i
+	}),
+	`string-last-index-of`: indexOf("string-last-index-of", func(str, sub string, from int) int {
+		if from >= 0 {
+			str = str[:byteIndex(str, from+len([]rune(sub)))]
+		}
+		return strings.LastIndex(str, sub)
+	}),
+}
+
+var stringsDocs = map[string]string{
+	`subs`:                 "Returns the characters of a string from start to end, or its end.",
+	`string-split`:         "Splits a string around a separator string or regex, into at most limit parts if given.",
+	`string-join`:          "Joins the elements of a collection as str does, separated by sep if given.",
+	`string-replace`:       "Replaces every match of a string or regex in a string.",
+	`string-replace-first`: "Replaces the first match of a string or regex in a string.",
+	`string-upper-case`:    "Returns a string in upper case.",
+	`string-lower-case`:    "Returns a string in lower case.",
+	`string-trim`:          "Removes white space from both ends of a string.",
+	`string-triml`:         "Removes white space from the start of a string.",
+	`string-trimr`:         "Removes white space from the end of a string.",
+	`string-trim-newline`:  "Removes newlines and carriage returns from the end of a string.",
+	`string-reverse`:       "Returns the characters of a string in reverse order.",
+	`string-starts-with?`:  "Returns true if a string starts with a prefix.",
+	`string-ends-with?`:    "Returns true if a string ends with a suffix.",
+	`string-includes?`:     "Returns true if a string contains another.",
+	`string-blank?`:        "Returns true if a string is nil, empty or white space.",
+	`string-index-of`:      "Returns the index of the first occurrence of a string in another, from an index if given, or nil.",
+	`string-last-index-of`: "Returns the index of the last occurrence of a string in another, up to an index if given, or nil.",
+}
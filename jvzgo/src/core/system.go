@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	. "types"
+)
+
+// Exit is called by the exit builtin. Programs embedding the interpreter can replace it to keep scripts from
+// ending the process.
+var Exit = os.Exit
+
+func init() {
+	for sym, fn := range systemNS {
+		NS[sym] = fn
+	}
+	for sym, doc := range systemDocs {
+		Docs[sym] = doc
+	}
+}
+
+var systemNS = map[string]MalType{
+	`getenv`: func(args []MalType) (MalType, error) {
+		switch len(args) {
+		case 0:
+			env := make(map[MalType]MalType)
+			for _, kv := range os.Environ() {
+				if eq := strings.IndexByte(kv, '='); eq >= 0 {
+					env[MalString{Value: kv[:eq]}] = MalString{Value: kv[eq+1:]}
+				}
+			}
+			return MalMap{Value: env}, nil
+		case 1:
+			name, err := GetString(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if val, ok := os.LookupEnv(name.Value); ok {
+				return MalString{Value: val}, nil
+			}
+			return MalNil{}, nil
+		default:
+			return nil, fmt.Errorf("getenv invalid args: %v", args)
+		}
+	},
+	`setenv`: BiErrFunc(func(a1 MalType, a2 MalType) (MalType, error) {
+		name, err := GetString(a1)
+		if err != nil {
+			return nil, err
+		}
+		if IsNil(a2) {
+			return MalNil{}, os.Unsetenv(name.Value)
+		}
+		val, err := GetString(a2)
+		if err != nil {
+			return nil, err
+		}
+		return MalNil{}, os.Setenv(name.Value, val.Value)
+	}),
+	`exit`: func(args []MalType) (MalType, error) {
+		code := 0
+		switch len(args) {
+		case 0:
+		case 1:
+			n, err := GetInt(args[0])
+			if err != nil {
+				return nil, err
+			}
+			code = n.Value
+		default:
+			return nil, fmt.Errorf("exit invalid args: %v", args)
+		}
+		Exit(code)
+		return MalNil{}, nil
+	},
+	`hostname`: func(args []MalType) (MalType, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("hostname invalid args: %v", args)
+		}
+		name, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		return MalString{Value: name}, nil
+	},
+	`pid`: func(args []MalType) (MalType, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("pid invalid args: %v", args)
+		}
+		return MalInt{Value: os.Getpid()}, nil
+	},
+	`cwd`: func(args []MalType) (MalType, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("cwd invalid args: %v", args)
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		return MalString{Value: dir}, nil
+	},
+	`chdir`: pathFunc(func(path string) (MalType, error) {
+		if err := os.Chdir(path); err != nil {
+			return nil, fsError("chdir", path, err)
+		}
+		return MalNil{}, nil
+	}),
+	`runtime-stats`: func(args []MalType) (MalType, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("runtime-stats invalid args: %v", args)
+		}
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		return MalMap{Value: map[MalType]MalType{
+			MalKeyword{Value: "goroutines"}:   MalInt{Value: runtime.NumGoroutine()},
+			MalKeyword{Value: "heap-alloc"}:   MalInt{Value: int(mem.HeapAlloc)},
+			MalKeyword{Value: "heap-sys"}:     MalInt{Value: int(mem.HeapSys)},
+			MalKeyword{Value: "heap-objects"}: MalInt{Value: int(mem.HeapObjects)},
+			MalKeyword{Value: "gc-count"}:     MalInt{Value: int(mem.NumGC)},
+			MalKeyword{Value: "cpus"}:         MalInt{Value: runtime.NumCPU()},
+		}}, nil
+	},
+}
+
+var systemDocs = map[string]string{
+	`getenv`:        "Returns the value of an environment variable, or nil, or a hash map of the whole environment with no name.",
+	`setenv`:        "Sets an environment variable, or unsets it given nil.",
+	`exit`:          "Exits the process with a status, 0 by default.",
+	`hostname`:      "Returns the host name.",
+	`pid`:           "Returns the process ID.",
+	`cwd`:           "Returns the current directory.",
+	`chdir`:         "Changes the current directory.",
+	`runtime-stats`: "Returns a hash map of the :goroutines, :heap-alloc, :heap-sys, :heap-objects, :gc-count and :cpus of the runtime.",
+}
@@ -2,20 +2,41 @@ package env
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 	. "types"
 )
 
+// Env maps symbols to values. The global environment is shared between goroutines, for example by closures run
+// in futures, so its bindings are kept in a sync.Map which supports lock-free reads and never exposes a map being
+// written.
+//
+// A local frame, made by a function call or let*, can be shared too: a future started in it reads it while let*
+// goes on binding names or a def! in the body adds one. Its bindings are kept in a plain map which is never
+// written once published. New populates the map before anything else can see the frame, and Set replaces it with
+// a copy holding the new binding, so reads need only an atomic load and let* frames need no sync.Map.
 type Env struct {
 	outer EnvType
-	data  map[string]MalType
+	// data holds the bindings of a local frame.
+	data atomic.Pointer[map[string]MalType]
+	// lock serialises Set on a local frame, so that concurrent copies do not lose bindings.
+	lock sync.Mutex
+	// defs holds the bindings of the global environment, and is nil in local frames.
+	defs *sync.Map
+	// metas holds the metadata of bindings, such as docstrings, and is allocated on first use.
+	metas atomic.Pointer[sync.Map]
 }
 
 func NewEnv() EnvType {
-	return &Env{data: make(map[string]MalType)}
+	return &Env{defs: new(sync.Map)}
 }
 
 func (env *Env) New(binds, exprs []MalType) (EnvType, error) {
-	inner := Env{outer: env, data: make(map[string]MalType)}
+	inner := &Env{outer: env}
+	if len(binds) == 0 {
+		return inner, nil
+	}
+	data := make(map[string]MalType, len(binds))
 	for i := 0; i < len(binds); i++ {
 		sym, err := GetSymbol(binds[i])
 		if err != nil {
@@ -26,23 +47,71 @@ func (env *Env) New(binds, exprs []MalType) (EnvType, error) {
 			if err != nil {
 				return nil, err
 			}
-			inner.Set(sym.Value, NewList(exprs[i:]))
+			data[sym.Value] = NewList(exprs[i:])
 			break
 		}
-		inner.Set(sym.Value, exprs[i])
+		data[sym.Value] = exprs[i]
 	}
-	return &inner, nil
+	inner.data.Store(&data)
+	return inner, nil
 }
 
 func (env *Env) Set(key string, val MalType) {
-	if env.data == nil {
-		env.data = make(map[string]MalType)
+	if env.defs != nil {
+		env.defs.Store(key, val)
+		return
+	}
+	env.lock.Lock()
+	defer env.lock.Unlock()
+	var data map[string]MalType
+	if old := env.data.Load(); old != nil {
+		data = make(map[string]MalType, len(*old)+1)
+		for k, v := range *old {
+			data[k] = v
+		}
+	} else {
+		data = make(map[string]MalType, 1)
+	}
+	data[key] = val
+	env.data.Store(&data)
+}
+
+func (env *Env) SetMeta(key string, meta MalType) {
+	metas := env.metas.Load()
+	if metas == nil {
+		env.metas.CompareAndSwap(nil, new(sync.Map))
+		metas = env.metas.Load()
 	}
-	env.data[key] = val
+	metas.Store(key, meta)
+}
+
+// Meta returns the metadata of the binding of key in the environment defining it, or nil.
+func (env *Env) Meta(key string) MalType {
+	found, ok := env.Find(key).(*Env)
+	if !ok {
+		return MalNil{}
+	}
+	if metas := found.metas.Load(); metas != nil {
+		if meta, ok := metas.Load(key); ok {
+			return meta
+		}
+	}
+	return MalNil{}
+}
+
+func (env *Env) lookup(key string) (MalType, bool) {
+	if env.defs != nil {
+		return env.defs.Load(key)
+	}
+	if data := env.data.Load(); data != nil {
+		val, ok := (*data)[key]
+		return val, ok
+	}
+	return nil, false
 }
 
 func (env *Env) Find(key string) EnvType {
-	if _, ok := env.data[key]; ok {
+	if _, ok := env.lookup(key); ok {
 		return env
 	} else if env.outer != nil {
 		return env.outer.Find(key)
@@ -52,9 +121,44 @@ func (env *Env) Find(key string) EnvType {
 }
 
 func (env *Env) Get(key string) (MalType, error) {
-	e := env.Find(key)
-	if e == nil {
-		return nil, fmt.Errorf("'%v' not found", key)
+	for e := env; ; {
+		if val, ok := e.lookup(key); ok {
+			return val, nil
+		}
+		if e.outer == nil {
+			return nil, fmt.Errorf("'%v' not found", key)
+		}
+		outer, ok := e.outer.(*Env)
+		if !ok {
+			return e.outer.Get(key)
+		}
+		e = outer
+	}
+}
+
+// Symbols lists the symbols bound in this environment and the environments enclosing it.
+func (env *Env) Symbols() []string {
+	seen := make(map[string]bool)
+	var syms []string
+	add := func(sym string) {
+		if !seen[sym] {
+			seen[sym] = true
+			syms = append(syms, sym)
+		}
+	}
+	for e := env; e != nil; {
+		if data := e.data.Load(); data != nil {
+			for sym := range *data {
+				add(sym)
+			}
+		}
+		if e.defs != nil {
+			e.defs.Range(func(key, _ interface{}) bool {
+				add(key.(string))
+				return true
+			})
+		}
+		e, _ = e.outer.(*Env)
 	}
-	return e.(*Env).data[key], nil
+	return syms
 }
@@ -0,0 +1,124 @@
+package env
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	. "types"
+)
+
+func syms(names ...string) []MalType {
+	res := make([]MalType, len(names))
+	for i, name := range names {
+		res[i] = MalSymbol{Value: name}
+	}
+	return res
+}
+
+func TestNewBindsParameters(t *testing.T) {
+	global := NewEnv()
+	global.Set("g", MalInt{Value: 1})
+	inner, err := global.New(syms("a", "&", "rest"), []MalType{MalInt{Value: 2}, MalInt{Value: 3}, MalInt{Value: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]MalType{
+		"g":    MalInt{Value: 1},
+		"a":    MalInt{Value: 2},
+		"rest": NewListOf(MalInt{Value: 3}, MalInt{Value: 4}),
+	} {
+		if got, err := inner.Get(key); err != nil || !Equal(got, want) {
+			t.Errorf("Get(%q) = %v, %v; want %v", key, got, err, want)
+		}
+	}
+	if _, err := inner.Get("missing"); err == nil {
+		t.Error("Get of an unbound symbol succeeded")
+	}
+	if inner.Find("g") != global || inner.Find("a") != inner {
+		t.Error("Find returned the wrong environment")
+	}
+}
+
+func TestLocalFrameSet(t *testing.T) {
+	global := NewEnv()
+	global.Set("x", MalInt{Value: 1})
+	inner, err := global.New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner.Set("x", MalInt{Value: 2})
+	inner.Set("y", MalInt{Value: 3})
+	if got, _ := inner.Get("x"); !Equal(got, MalInt{Value: 2}) {
+		t.Errorf("local x = %v", got)
+	}
+	if got, _ := global.Get("x"); !Equal(got, MalInt{Value: 1}) {
+		t.Errorf("global x = %v after shadowing it", got)
+	}
+	if _, err := global.Get("y"); err == nil {
+		t.Error("a local binding leaked into the global environment")
+	}
+	got := inner.(*Env).Symbols()
+	sort.Strings(got)
+	if fmt.Sprint(got) != "[x y]" {
+		t.Errorf("Symbols() = %v", got)
+	}
+}
+
+// TestConcurrentGlobals defines and reads globals from many goroutines at once, as futures calling def! do, and
+// is meant to be run with -race.
+func TestConcurrentGlobals(t *testing.T) {
+	global := NewEnv()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("v%d", j%10)
+				global.Set(key, MalInt{Value: i})
+				if _, err := global.Get(key); err != nil {
+					t.Error(err)
+					return
+				}
+				global.SetMeta(key, MalMap{Value: map[MalType]MalType{MalKeyword{Value: "doc"}: MalString{Value: key}}})
+				global.Meta(key)
+				global.(*Env).Symbols()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := len(global.(*Env).Symbols()); n != 10 {
+		t.Errorf("%d globals defined, want 10", n)
+	}
+}
+
+// TestSharedLocalFrames reads a populated local frame, as a closure run in several futures does, from many
+// goroutines while the global environment is written.
+func TestSharedLocalFrames(t *testing.T) {
+	global := NewEnv()
+	frame, err := global.New(syms("a", "b"), []MalType{MalInt{Value: 1}, MalInt{Value: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	let, _ := frame.New(nil, nil)
+	let.Set("c", MalInt{Value: 3})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				global.Set(fmt.Sprintf("g%d", i), MalInt{Value: j})
+				inner, _ := let.New(syms("d"), []MalType{MalInt{Value: j}})
+				for _, key := range []string{"a", "b", "c", "d"} {
+					if _, err := inner.Get(key); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
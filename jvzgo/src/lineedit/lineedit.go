@@ -0,0 +1,457 @@
+// Package lineedit reads lines from a terminal with editing, history and completion, without cgo. It understands
+// the usual Emacs keys: the arrows, Home, End and Delete, Ctrl-A, E, B, F, K, U, W, H and L, Alt-B and F for words,
+// Ctrl-P and N or the up and down arrows for history, Ctrl-R for reverse incremental search through the history
+// and Tab for completion.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned when the line being edited is abandoned with Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+const maxHistory = 1000
+
+// delimiters separate the words which are completed and deleted with Ctrl-W.
+const delimiters = " \t()[]{}'\"`,;@^~"
+
+// Editor reads lines from standard input, which must be a terminal.
+type Editor struct {
+	in      io.RuneReader
+	out     *bufio.Writer
+	fd      int
+	history []string
+	// historyFile is where lines are appended as they are entered, or "" to keep no file.
+	historyFile string
+	// Complete returns the possible completions of the word before the cursor.
+	Complete func(word string) []string
+}
+
+// unsupported lists the values of TERM for terminals which do not understand the escape sequences used.
+var unsupported = map[string]bool{"": true, "dumb": true, "cons25": true, "emacs": true}
+
+// Supported reports whether standard input and output are a terminal which the editor can use.
+func Supported() bool {
+	return !unsupported[os.Getenv("TERM")] && isTerminal(int(os.Stdin.Fd())) && isTerminal(int(os.Stdout.Fd()))
+}
+
+// New creates an editor reading keys from in, with the history read from historyFile, to which each line entered
+// is appended. in must read standard input, and should be the reader the rest of the program reads it through, so
+// that input buffered by one is not lost to the other.
+func New(in io.RuneReader, historyFile string) *Editor {
+	e := &Editor{
+		in:          in,
+		out:         bufio.NewWriter(os.Stdout),
+		fd:          int(os.Stdin.Fd()),
+		historyFile: historyFile,
+	}
+	if content, err := ioutil.ReadFile(historyFile); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			if line != "" {
+				e.history = append(e.history, line)
+			}
+		}
+		e.trimHistory()
+	}
+	return e
+}
+
+func (e *Editor) trimHistory() {
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// AddHistory adds a line to the history unless it is blank or repeats the last one.
+func (e *Editor) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	e.trimHistory()
+	if e.historyFile == "" {
+		return
+	}
+	if f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+		f.WriteString(line + "\n")
+		f.Close()
+	}
+}
+
+// ReadLine shows the prompt and returns the line entered, which is added to the history. It returns io.EOF when
+// Ctrl-D is pressed on an empty line and ErrInterrupted when Ctrl-C is pressed.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	s := &state{e: e, prompt: prompt, histPos: len(e.history)}
+	s.refresh()
+	line, err := s.edit()
+	if err == ErrInterrupted {
+		e.out.WriteString("^C")
+	}
+	e.out.WriteString("\n")
+	e.out.Flush()
+	if err == nil {
+		e.AddHistory(line)
+	}
+	return line, err
+}
+
+// state is the line being edited.
+type state struct {
+	e      *Editor
+	prompt string
+	buf    []rune
+	pos    int
+	// histPos is the index of the history entry shown, or len(history) for the new line, which is kept in saved
+	// while moving through the history.
+	histPos int
+	saved   []rune
+	// searching is set during a Ctrl-R search for query, whose latest match is the history entry at match.
+	searching bool
+	query     []rune
+	match     int
+	failed    bool
+}
+
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
+
+func (s *state) edit() (string, error) {
+	for {
+		r, _, err := s.e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if s.searching && s.searchKey(r) {
+			s.refresh()
+			continue
+		}
+		switch r {
+		case '\r', '\n':
+			return string(s.buf), nil
+		case ctrl('C'):
+			return "", ErrInterrupted
+		case ctrl('D'):
+			if len(s.buf) == 0 {
+				return "", io.EOF
+			}
+			s.delete(s.pos, s.pos+1)
+		case ctrl('A'):
+			s.pos = 0
+		case ctrl('E'):
+			s.pos = len(s.buf)
+		case ctrl('B'):
+			s.move(-1)
+		case ctrl('F'):
+			s.move(1)
+		case ctrl('H'), 127:
+			s.delete(s.pos-1, s.pos)
+		case ctrl('K'):
+			s.delete(s.pos, len(s.buf))
+		case ctrl('U'):
+			s.delete(0, s.pos)
+		case ctrl('W'):
+			s.delete(s.wordStart(), s.pos)
+		case ctrl('L'):
+			s.e.out.WriteString("\x1b[H\x1b[2J")
+		case ctrl('P'):
+			s.showHistory(s.histPos - 1)
+		case ctrl('N'):
+			s.showHistory(s.histPos + 1)
+		case ctrl('R'):
+			s.searching, s.query, s.match, s.failed = true, nil, len(s.e.history), false
+		case '\t':
+			s.complete()
+		case 27:
+			s.escape()
+		default:
+			if r >= ' ' {
+				s.insert([]rune{r})
+			}
+		}
+		s.refresh()
+	}
+}
+
+// escape handles the keys which send escape sequences.
+func (s *state) escape() {
+	b, _, err := s.e.in.ReadRune()
+	if err != nil {
+		return
+	}
+	switch b {
+	case 'b':
+		s.pos = s.wordStart()
+	case 'f':
+		s.pos = s.wordEnd()
+	case '[', 'O':
+		var params []rune
+		for {
+			c, _, err := s.e.in.ReadRune()
+			if err != nil {
+				return
+			}
+			if c >= 0x40 && c <= 0x7e {
+				b = c
+				break
+			}
+			params = append(params, c)
+		}
+		switch b {
+		case 'A':
+			s.showHistory(s.histPos - 1)
+		case 'B':
+			s.showHistory(s.histPos + 1)
+		case 'C':
+			s.move(1)
+		case 'D':
+			s.move(-1)
+		case 'H':
+			s.pos = 0
+		case 'F':
+			s.pos = len(s.buf)
+		case '~':
+			switch string(params) {
+			case "1", "7":
+				s.pos = 0
+			case "4", "8":
+				s.pos = len(s.buf)
+			case "3":
+				s.delete(s.pos, s.pos+1)
+			}
+		}
+	}
+}
+
+func (s *state) move(n int) {
+	if pos := s.pos + n; pos >= 0 && pos <= len(s.buf) {
+		s.pos = pos
+	}
+}
+
+func (s *state) insert(runes []rune) {
+	buf := make([]rune, 0, len(s.buf)+len(runes))
+	buf = append(append(append(buf, s.buf[:s.pos]...), runes...), s.buf[s.pos:]...)
+	s.buf = buf
+	s.pos += len(runes)
+}
+
+// delete removes the runes from start to end, clipped to the line.
+func (s *state) delete(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(s.buf) {
+		end = len(s.buf)
+	}
+	if start >= end {
+		return
+	}
+	s.buf = append(s.buf[:start:start], s.buf[end:]...)
+	if s.pos > end {
+		s.pos -= end - start
+	} else if s.pos > start {
+		s.pos = start
+	}
+}
+
+func isDelimiter(r rune) bool {
+	return strings.ContainsRune(delimiters, r)
+}
+
+// wordStart returns the start of the word before the cursor, skipping delimiters right before it.
+func (s *state) wordStart() int {
+	i := s.pos
+	for i > 0 && isDelimiter(s.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !isDelimiter(s.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor, skipping delimiters right after it.
+func (s *state) wordEnd() int {
+	i := s.pos
+	for i < len(s.buf) && isDelimiter(s.buf[i]) {
+		i++
+	}
+	for i < len(s.buf) && !isDelimiter(s.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// showHistory replaces the line with the history entry at i, or with the new line past the last entry.
+func (s *state) showHistory(i int) {
+	history := s.e.history
+	if i < 0 || i > len(history) || i == s.histPos {
+		return
+	}
+	if s.histPos == len(history) {
+		s.saved = s.buf
+	}
+	s.histPos = i
+	if i == len(history) {
+		s.buf = s.saved
+	} else {
+		s.buf = []rune(history[i])
+	}
+	s.pos = len(s.buf)
+}
+
+// searchKey handles a key during a Ctrl-R search, returning false when the key ends the search and should then be
+// handled as usual. The line found is kept when the search ends, except with Ctrl-G, which restores the line.
+func (s *state) searchKey(r rune) bool {
+	switch r {
+	case ctrl('R'):
+		s.search(s.match - 1)
+		return true
+	case ctrl('G'):
+		s.searching = false
+		return true
+	case ctrl('H'), 127:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+			s.search(len(s.e.history) - 1)
+		}
+		return true
+	}
+	if r >= ' ' && r != 127 {
+		s.query = append(s.query, r)
+		s.search(s.match)
+		return true
+	}
+	s.searching = false
+	if s.match < len(s.e.history) {
+		s.histPos = len(s.e.history)
+		s.buf = []rune(s.e.history[s.match])
+		s.pos = len(s.buf)
+	}
+	s.refresh()
+	return false
+}
+
+// search looks backwards through the history from the entry at i for one containing the query.
+func (s *state) search(i int) {
+	if i >= len(s.e.history) {
+		i = len(s.e.history) - 1
+	}
+	query := string(s.query)
+	for ; i >= 0; i-- {
+		if strings.Contains(s.e.history[i], query) {
+			s.match, s.failed = i, false
+			return
+		}
+	}
+	s.failed = true
+}
+
+// complete extends the word before the cursor to the longest prefix shared by its completions, or lists them when
+// it cannot be extended.
+func (s *state) complete() {
+	if s.e.Complete == nil {
+		return
+	}
+	start := s.pos
+	for start > 0 && !isDelimiter(s.buf[start-1]) {
+		start--
+	}
+	word := string(s.buf[start:s.pos])
+	candidates := s.e.Complete(word)
+	if len(candidates) == 0 {
+		s.e.out.WriteString("\a")
+		return
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	if len(prefix) > len(word) && strings.HasPrefix(prefix, word) {
+		s.insert([]rune(prefix[len(word):]))
+		return
+	}
+	if len(candidates) > 1 {
+		s.list(candidates)
+	}
+}
+
+// list prints completions in columns below the line, which is redrawn afterwards.
+func (s *state) list(candidates []string) {
+	sort.Strings(candidates)
+	width := 0
+	for _, c := range candidates {
+		if n := utf8.RuneCountInString(c); n > width {
+			width = n
+		}
+	}
+	width += 2
+	cols := terminalWidth(s.e.fd) / width
+	if cols < 1 {
+		cols = 1
+	}
+	s.e.out.WriteString("\n")
+	for i, c := range candidates {
+		if i%cols == cols-1 || i == len(candidates)-1 {
+			s.e.out.WriteString(c + "\n")
+		} else {
+			fmt.Fprintf(s.e.out, "%-*s", width, c)
+		}
+	}
+}
+
+// refresh redraws the line, scrolling it sideways when it is wider than the terminal so that the cursor stays
+// visible. A prompt which is itself too wide is cut from the left.
+func (s *state) refresh() {
+	prompt, buf, pos := s.prompt, s.buf, s.pos
+	if s.searching {
+		prompt = "(reverse-i-search)`" + string(s.query) + "': "
+		if s.failed {
+			prompt = "(failing " + prompt[1:]
+		}
+		buf = nil
+		if s.match < len(s.e.history) {
+			buf = []rune(s.e.history[s.match])
+		}
+		pos = len(buf)
+	}
+	width := terminalWidth(s.e.fd)
+	if runes := []rune(prompt); len(runes) > width-1 {
+		prompt = string(runes[len(runes)-max(width-1, 0):])
+	}
+	promptWidth := utf8.RuneCountInString(prompt)
+	start := 0
+	if promptWidth+pos >= width {
+		start = promptWidth + pos - width + 1
+	}
+	end := len(buf)
+	if promptWidth+end-start >= width {
+		end = start + width - promptWidth - 1
+	}
+	start = min(start, len(buf))
+	end = min(max(end, start), len(buf))
+	out := s.e.out
+	out.WriteString("\r" + prompt + string(buf[start:end]) + "\x1b[K\r")
+	if col := promptWidth + pos - start; col > 0 {
+		fmt.Fprintf(out, "\x1b[%dC", col)
+	}
+	out.Flush()
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package lineedit
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func isTerminal(fd int) bool {
+	var t syscall.Termios
+	return ioctl(fd, syscall.TCGETS, unsafe.Pointer(&t)) == nil
+}
+
+// makeRaw turns off line buffering, echo and signal keys so that every key press can be read as it happens, and
+// returns a function restoring the previous mode. Output processing is left on so that "\n" still starts a new line.
+func makeRaw(fd int) (func(), error) {
+	var old syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&old)); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Cflag |= syscall.CS8
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(fd, syscall.TCSETS, unsafe.Pointer(&old))
+	}, nil
+}
+
+func terminalWidth(fd int) int {
+	var size struct {
+		rows, cols, xpixels, ypixels uint16
+	}
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil || size.cols == 0 {
+		return 80
+	}
+	return int(size.cols)
+}
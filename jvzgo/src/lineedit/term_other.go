@@ -0,0 +1,19 @@
+//go:build !linux
+
+package lineedit
+
+import "errors"
+
+// Raw mode is only implemented for Linux. Elsewhere Supported reports false and the REPL reads plain lines.
+
+func isTerminal(int) bool {
+	return false
+}
+
+func makeRaw(int) (func(), error) {
+	return nil, errors.New("line editing is not supported on this platform")
+}
+
+func terminalWidth(int) int {
+	return 80
+}
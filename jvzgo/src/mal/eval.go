@@ -0,0 +1,437 @@
+package mal
+
+import (
+	"errors"
+	"fmt"
+	. "types"
+)
+
+// SpecialForms are the symbols Eval treats specially at the head of a list.
+var SpecialForms = []string{"def!", "let*", "do", "if", "fn*", "quote", "quasiquote", "defmacro!", "macroexpand",
+	"try*", "catch*", ".", "select", "var"}
+
+func evalAst(ast MalType, env EnvType) (MalType, error) {
+	switch ast := ast.(type) {
+	case MalSymbol:
+		return env.Get(ast.Value)
+	case MalList:
+		evals := make([]MalType, len(ast.Value))
+		for i, arg := range ast.Value {
+			res, err := Eval(arg, env)
+			if err != nil {
+				return nil, err
+			}
+			evals[i] = res
+		}
+		return ast.New(evals), nil
+	case MalMap:
+		evals := make(map[MalType]MalType)
+		for k, v := range ast.Value {
+			res, err := Eval(v, env)
+			if err != nil {
+				return nil, err
+			}
+			evals[k] = res
+		}
+		return MalMap{Value: evals}, nil
+	default:
+		return ast, nil
+	}
+}
+
+// Eval evaluates ast in env.
+func Eval(ast MalType, env EnvType) (MalType, error) {
+	for {
+		if !IsList(ast) {
+			return evalAst(ast, env)
+		}
+		exp, err := macroexpand(ast, env)
+		if err != nil {
+			return nil, err
+		}
+		if !IsList(exp) {
+			return evalAst(exp, env)
+		}
+		list := exp.(MalList).Value
+		if len(list) == 0 {
+			return ast, nil
+		}
+		ast = exp
+		sym := "__<*fn*>__"
+		if s, ok := list[0].(MalSymbol); ok {
+			sym = s.Value
+		}
+		var a1 MalType
+		var a2 MalType
+		switch len(list) {
+		case 1:
+			a1 = nil
+			a2 = nil
+		case 2:
+			a1 = list[1]
+			a2 = nil
+		default:
+			a1 = list[1]
+			a2 = list[2]
+		}
+		switch sym {
+		case "def!":
+			// define a symbol in the given env
+			key, meta, form, err := definition(list, env)
+			if err != nil {
+				return nil, err
+			}
+			val, err := Eval(form, env)
+			if err != nil {
+				return nil, err
+			}
+			define(env, key, val, meta)
+			return val, nil
+
+		case "let*":
+			// create an inner env with ordered bindings and apply it to an expression
+			if len(list) != 3 {
+				return nil, fmt.Errorf("let* invalid args: %v", list)
+			}
+			binds, err := GetSlice(a1)
+			if err != nil {
+				return nil, err
+			}
+			if len(binds)&1 == 1 {
+				return nil, errors.New("odd number of binds provided to let*")
+			}
+			inner, err := env.New(nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < len(binds); i += 2 {
+				sym, err := GetSymbol(binds[i])
+				if err != nil {
+					return nil, err
+				}
+				expr, err := Eval(binds[i+1], inner)
+				if err != nil {
+					return nil, err
+				}
+				inner.Set(sym.Value, expr)
+			}
+			env = inner
+			ast = a2
+			continue
+
+		case "do":
+			// evaluate all arguments and return the last one's result
+			switch len(list) {
+			case 1:
+				return MalNil{}, nil
+			case 2:
+				ast = list[1]
+				continue
+			default:
+				if _, err := evalAst(NewList(list[1:len(list)-1]), env); err != nil {
+					return nil, err
+				}
+				ast = list[len(list)-1]
+				continue
+			}
+
+		case "if":
+			// check first arg, if not nil or false, evaluates and returns second arg
+			// otherwise, the third arg is evaluated and returned if provided or nil otherwise
+			if len(list) < 3 || len(list) > 4 {
+				return nil, fmt.Errorf("if invalid args: %v", list)
+			}
+			expr, err := Eval(a1, env)
+			switch {
+			case err != nil:
+				return nil, err
+			case IsTruthy(expr):
+				ast = a2
+				continue
+			case len(list) < 4:
+				return MalNil{}, nil
+			default:
+				ast = list[3]
+				continue
+			}
+
+		case "fn*":
+			// create a new function closure
+			if len(list) != 3 {
+				return nil, fmt.Errorf("fn* invalid args: %v", list)
+			}
+			binds, err := GetSlice(a1)
+			if err != nil {
+				return nil, err
+			}
+			return NewFunc(Eval, binds, a2, env), nil
+
+		case "quote":
+			if len(list) != 2 {
+				return nil, fmt.Errorf("quote invalid args: %v", list)
+			}
+			return a1, nil
+
+		case "quasiquote":
+			if len(list) != 2 {
+				return nil, fmt.Errorf("quasiquote invalid args: %v", list)
+			}
+			ast = quasiquote(a1)
+			continue
+
+		case "defmacro!":
+			// defines a macro symbol in the given env
+			key, meta, form, err := definition(list, env)
+			if err != nil {
+				return nil, err
+			}
+			val, err := Eval(form, env)
+			if err != nil {
+				return nil, err
+			}
+			fn, ok := val.(MalFunc)
+			if !ok {
+				return RaiseTypeError("function", val)
+			}
+			fn.SetMacro(true)
+			define(env, key, fn, meta)
+			return fn, nil
+
+		case "var":
+			// refer to the binding of a symbol rather than its value
+			if len(list) != 2 {
+				return nil, fmt.Errorf("var invalid args: %v", list)
+			}
+			key, err := GetSymbol(a1)
+			if err != nil {
+				return nil, err
+			}
+			found := env.Find(key.Value)
+			if found == nil {
+				return nil, fmt.Errorf("'%v' not found", key.Value)
+			}
+			return NewVar(key.Value, found), nil
+
+		case "macroexpand":
+			return macroexpand(a1, env)
+
+		case "try*":
+			if len(list) != 3 {
+				return nil, fmt.Errorf("try* invalid args: %v", list)
+			}
+			catch, err := GetList(a2)
+			if err != nil {
+				return nil, err
+			}
+			if len(catch.Value) != 3 {
+				return nil, fmt.Errorf("catch* invalid args: %v", catch.Value)
+			}
+			sym, err := GetSymbol(catch.Value[0])
+			if err != nil {
+				return RaiseTypeError("symbol", catch.Value[0])
+			}
+			if sym.Value != "catch*" {
+				return RaiseTypeError("catch* symbol", sym)
+			}
+			try, err := Eval(a1, env)
+			if err == nil || errors.Is(err, ErrRetry) {
+				// a transaction being retried must unwind to its dosync
+				return try, err
+			}
+			inner, err := env.New(catch.Value[1:2], []MalType{ErrorValue(err)})
+			if err != nil {
+				return nil, err
+			}
+			return Eval(catch.Value[2], inner)
+
+		case ".":
+			// call a method of a Go value or read one of its fields: (. obj Name args...)
+			if len(list) < 3 {
+				return nil, fmt.Errorf(". invalid args: %v", list)
+			}
+			name, err := GetSymbol(a2)
+			if err != nil {
+				return nil, err
+			}
+			obj, err := Eval(a1, env)
+			if err != nil {
+				return nil, err
+			}
+			args, err := evalAst(NewList(list[3:]), env)
+			if err != nil {
+				return nil, err
+			}
+			return member(obj, name.Value, args.(MalList).Value)
+
+		case "select":
+			// wait for the first of several channels to be ready and evaluate its clause with the value taken
+			// bound to the clause's symbol: (select [sym chan] expr ... :default expr)
+			if len(list)&1 != 1 {
+				return nil, fmt.Errorf("select invalid args: %v", list)
+			}
+			var ops []ChanOp
+			var syms, exprs []MalType
+			var deflt MalType
+			for i := 1; i < len(list); i += 2 {
+				if kw, ok := list[i].(MalKeyword); ok && kw.Value == "default" {
+					deflt = list[i+1]
+					continue
+				}
+				binding, err := GetVec(list[i])
+				if err != nil || len(binding.Value) != 2 {
+					return nil, fmt.Errorf("select invalid binding: %v", list[i])
+				}
+				val, err := Eval(binding.Value[1], env)
+				if err != nil {
+					return nil, err
+				}
+				ch, err := GetChan(val)
+				if err != nil {
+					return nil, err
+				}
+				ops = append(ops, ChanOp{Chan: ch})
+				syms = append(syms, binding.Value[0])
+				exprs = append(exprs, list[i+1])
+			}
+			i, val, err := Select(ops, deflt == nil)
+			if err != nil {
+				return nil, fmt.Errorf("select %w", err)
+			}
+			if i < 0 {
+				ast = deflt
+				continue
+			}
+			inner, err := env.New(syms[i:i+1], []MalType{val})
+			if err != nil {
+				return nil, err
+			}
+			env = inner
+			ast = exprs[i]
+			continue
+
+		default:
+			// evaluate functions
+			eval, err := evalAst(ast, env)
+			if err != nil {
+				return nil, err
+			}
+			evals, err := GetSlice(eval)
+			if err != nil {
+				return nil, err
+			}
+			fn, err := GetFn(evals[0])
+			if err != nil {
+				return nil, err
+			}
+			res, err := fn(evals[1:])
+			if err != nil {
+				return nil, AddFrame(err, ast)
+			}
+			if f, ok := res.(func([]MalType) (MalType, error)); ok {
+				// give builtins returned by builtins, such as partial, an identity for =
+				return NewFn(f), nil
+			}
+			return res, nil
+		}
+	}
+}
+
+// definition splits a def! or defmacro! form into the symbol defined, the form giving its value and the metadata
+// of the binding: the name, the entries of an optional attribute map and an optional docstring given before it.
+func definition(list []MalType, env EnvType) (MalSymbol, map[MalType]MalType, MalType, error) {
+	if len(list) < 3 || len(list) > 5 {
+		return MalSymbol{}, nil, nil, fmt.Errorf("%v invalid args: %v", list[0], list)
+	}
+	key, err := GetSymbol(list[1])
+	if err != nil {
+		return MalSymbol{}, nil, nil, err
+	}
+	meta := make(map[MalType]MalType)
+	attrs := list[2 : len(list)-1]
+	doc, hasDoc := MalType(nil), false
+	if len(attrs) > 0 {
+		doc, hasDoc = attrs[0].(MalString)
+		if hasDoc {
+			attrs = attrs[1:]
+		}
+	}
+	if len(attrs) > 0 {
+		if !IsMap(attrs[0]) || len(attrs) > 1 {
+			return MalSymbol{}, nil, nil, fmt.Errorf("%v invalid args: %v", list[0], list)
+		}
+		val, err := Eval(attrs[0], env)
+		if err != nil {
+			return MalSymbol{}, nil, nil, err
+		}
+		for k, v := range val.(MalMap).Value {
+			meta[k] = v
+		}
+	}
+	if hasDoc {
+		meta[MalKeyword{Value: "doc"}] = doc
+	}
+	meta[MalKeyword{Value: "name"}] = key
+	return key, meta, list[len(list)-1], nil
+}
+
+// define binds key to val in env with the metadata of its definition, adding the parameters of a function.
+func define(env EnvType, key MalSymbol, val MalType, meta map[MalType]MalType) {
+	if fn, ok := val.(MalFunc); ok {
+		meta[MalKeyword{Value: "arglists"}] = NewListOf(NewVec(fn.Params()))
+	}
+	env.Set(key.Value, val)
+	env.SetMeta(key.Value, MalMap{Value: meta})
+}
+
+func isPair(val MalType) bool {
+	list, ok := val.(MalList)
+	return ok && len(list.Value) > 0
+}
+
+func quasiquote(ast MalType) MalType {
+	if !isPair(ast) {
+		return NewListOf(MalSymbol{Value: "quote"}, ast)
+	}
+	list, _ := GetSlice(ast)
+	if sym, ok := list[0].(MalSymbol); ok && sym.Value == "unquote" {
+		return list[1]
+	}
+	if isPair(list[0]) {
+		inner, _ := GetSlice(list[0])
+		if sym, ok := inner[0].(MalSymbol); ok && sym.Value == "splice-unquote" {
+			return NewListOf(MalSymbol{Value: "concat"}, inner[1], quasiquote(NewList(list[1:])))
+		}
+	}
+	return NewListOf(MalSymbol{Value: "cons"}, quasiquote(list[0]), quasiquote(NewList(list[1:])))
+}
+
+func isMacroCall(ast MalType, env EnvType) bool {
+	if !isPair(ast) {
+		return false
+	}
+	list := ast.(MalList).Value
+	if sym, ok := list[0].(MalSymbol); ok {
+		val, err := env.Get(sym.Value)
+		if err != nil {
+			return false
+		}
+		fn, ok := val.(MalFunc)
+		return ok && fn.IsMacro()
+	}
+	return false
+}
+
+func macroexpand(ast MalType, env EnvType) (MalType, error) {
+	for isMacroCall(ast, env) {
+		list := ast.(MalList).Value
+		sym := list[0].(MalSymbol)
+		val, _ := env.Get(sym.Value)
+		fn := val.(MalFunc)
+		res, err := fn.Fn()(list[1:])
+		if err != nil {
+			return nil, err
+		}
+		ast = res
+	}
+	return ast, nil
+}
@@ -0,0 +1,174 @@
+package mal
+
+import (
+	"core"
+	"fmt"
+	"reader"
+	"sort"
+	"strings"
+	. "types"
+)
+
+// helpPrelude defines the macros which let doc, source and dir take an unquoted name.
+var helpPrelude = []string{
+	"(defmacro! doc \"Prints the documentation of a name.\" (fn* (name) `(doc* '~name)))",
+	"(defmacro! source \"Prints the definition of a name.\" (fn* (name) `(source* '~name)))",
+	"(defmacro! dir \"Prints the names bound in a namespace, user by default.\" (fn* (& ns) `(dir* '~(first ns))))",
+}
+
+// userNS is the name of the only namespace, which holds every global binding.
+const userNS = "user"
+
+// definedName returns the name a top-level def! or defmacro! form defines, after macro expansion.
+func definedName(ast MalType) (string, bool) {
+	list, ok := ast.(MalList)
+	if !ok || !IsList(list) || len(list.Value) < 3 {
+		return "", false
+	}
+	head, ok := list.Value[0].(MalSymbol)
+	if !ok || (head.Value != "def!" && head.Value != "defmacro!") {
+		return "", false
+	}
+	name, ok := list.Value[1].(MalSymbol)
+	return name.Value, ok
+}
+
+// EvalForm evaluates a form read from source code, keeping the text of top-level definitions, including ones
+// made by macros such as defn, for source and adding where they were read to the metadata of their bindings.
+func (in *Interpreter) EvalForm(form reader.Form) (MalType, error) {
+	ast, err := macroexpand(form.Value, in.env)
+	if err != nil {
+		return nil, err
+	}
+	res, err := in.Eval(ast)
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := definedName(ast); ok {
+		in.sources.Store(name, form)
+		if meta, ok := in.env.Meta(name).(MalMap); ok && form.File != "" {
+			meta = CopyMap(meta)
+			meta.Value[MalKeyword{Value: "file"}] = MalString{Value: form.File}
+			meta.Value[MalKeyword{Value: "line"}] = MalInt{Value: form.Line}
+			in.env.SetMeta(name, meta)
+		}
+	}
+	return res, nil
+}
+
+// Source returns the form which last defined name, if it was read from source code.
+func (in *Interpreter) Source(name string) (reader.Form, bool) {
+	form, ok := in.sources.Load(name)
+	if !ok {
+		return reader.Form{}, false
+	}
+	return form.(reader.Form), true
+}
+
+// isSpecialForm reports whether name is one of the SpecialForms.
+func isSpecialForm(name string) bool {
+	for _, sym := range SpecialForms {
+		if sym == name {
+			return true
+		}
+	}
+	return false
+}
+
+// doc prints the documentation of the binding of name from its metadata: its parameters, whether it is a macro,
+// where it was defined and its docstring. Values without a documented binding fall back to their own :doc.
+func (in *Interpreter) doc(name string) {
+	if isSpecialForm(name) {
+		fmt.Printf("-------------------------\n%s\nSpecial Form\n", name)
+		return
+	}
+	val, err := in.env.Get(name)
+	if err != nil {
+		return
+	}
+	meta, _ := in.env.Meta(name).(MalMap)
+	entry := func(key string) MalType {
+		if entry, ok := meta.Value[MalKeyword{Value: key}]; ok {
+			return entry
+		}
+		return MalNil{}
+	}
+	fmt.Printf("-------------------------\n%s\n", name)
+	if arglists := entry("arglists"); !IsNil(arglists) {
+		fmt.Println(Print(arglists, true))
+	}
+	if IsMacro(val) {
+		fmt.Println("Macro")
+	}
+	if file, ok := entry("file").(MalString); ok {
+		fmt.Printf("%s:%v\n", file.Value, Print(entry("line"), true))
+	}
+	doc, ok := entry("doc").(MalString)
+	if !ok {
+		valMeta, _ := GetMeta(val).(MalMap)
+		doc, ok = valMeta.Value[MalKeyword{Value: "doc"}].(MalString)
+	}
+	if ok {
+		fmt.Printf("  %s\n", doc.Value)
+	}
+}
+
+// defineHelp binds the builtins for exploring the global environment.
+func (in *Interpreter) defineHelp() {
+	in.defineBuiltin("doc*", "Prints the documentation of the name a symbol gives.", core.MonoErrFunc(func(a MalType) (MalType, error) {
+		name, ok := a.(MalSymbol)
+		if !ok {
+			return nil, fmt.Errorf("doc invalid args: %v", a)
+		}
+		in.doc(name.Value)
+		return MalNil{}, nil
+	}))
+	in.defineBuiltin("source*", "Prints the definition of the name a symbol gives.", core.MonoErrFunc(func(a MalType) (MalType, error) {
+		name, ok := a.(MalSymbol)
+		if !ok {
+			return nil, fmt.Errorf("source invalid args: %v", a)
+		}
+		if form, ok := in.Source(name.Value); ok {
+			fmt.Println(form.Text)
+		} else {
+			fmt.Println("Source not found")
+		}
+		return MalNil{}, nil
+	}))
+	in.defineBuiltin("apropos", "Returns a sorted list of the bound names containing a string or matching a regex.", core.MonoErrFunc(func(a MalType) (MalType, error) {
+		var match func(string) bool
+		switch a := a.(type) {
+		case MalString:
+			match = func(sym string) bool { return strings.Contains(sym, a.Value) }
+		case MalRegex:
+			match = a.Value.MatchString
+		default:
+			return nil, fmt.Errorf("apropos invalid args: %v", a)
+		}
+		var syms []string
+		for _, sym := range in.Symbols() {
+			if match(sym) {
+				syms = append(syms, sym)
+			}
+		}
+		sort.Strings(syms)
+		res := make([]MalType, len(syms))
+		for i, sym := range syms {
+			res[i] = MalSymbol{Value: sym}
+		}
+		return NewList(res), nil
+	}))
+	in.defineBuiltin("dir*", "Prints the names bound in the namespace a symbol gives, or user given nil.", core.MonoErrFunc(func(a MalType) (MalType, error) {
+		if ns, ok := a.(MalSymbol); ok && ns.Value != userNS {
+			return nil, fmt.Errorf("no namespace: %s", ns.Value)
+		} else if !ok && !IsNil(a) {
+			return nil, fmt.Errorf("dir invalid args: %v", a)
+		}
+		syms := in.Symbols()
+		sort.Strings(syms)
+		for _, sym := range syms {
+			fmt.Println(sym)
+		}
+		return MalNil{}, nil
+	}))
+}
@@ -0,0 +1,438 @@
+package mal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	. "types"
+)
+
+var (
+	malType   = reflect.TypeOf((*MalType)(nil)).Elem()
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	typesPath = reflect.TypeOf(MalNil{}).PkgPath()
+)
+
+// fromGo converts a Go value to mal. Booleans, integers, strings, slices, arrays and maps are converted to their
+// mal equivalents and functions are wrapped as builtins. Values of the mal types are returned as they are and
+// anything else is wrapped in a MalGo.
+func fromGo(v reflect.Value) (MalType, error) {
+	if !v.IsValid() {
+		return MalNil{}, nil
+	}
+	if t := v.Type(); (t.PkgPath() == typesPath || t.Kind() == reflect.Ptr && t.Elem().PkgPath() == typesPath) &&
+		v.CanInterface() {
+		return v.Interface(), nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if v.IsNil() {
+			return MalNil{}, nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return fromGo(v.Elem())
+	case reflect.Bool:
+		return MalBool{Value: v.Bool()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return MalInt{Value: int(v.Int())}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return MalInt{Value: int(v.Uint())}, nil
+	case reflect.String:
+		return MalString{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		vals, err := fromGoValues(v.Len(), v.Index)
+		if err != nil {
+			return nil, err
+		}
+		return NewVec(vals), nil
+	case reflect.Map:
+		m := make(map[MalType]MalType, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := fromGo(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			if key, err = MapKey(key); err != nil {
+				return nil, err
+			}
+			val, err := fromGo(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[key] = val
+		}
+		return MalMap{Value: m}, nil
+	case reflect.Func:
+		return NewFn(wrapFunc(v)), nil
+	default:
+		if !v.CanInterface() {
+			return MalNil{}, nil
+		}
+		return MalGo{Value: v.Interface()}, nil
+	}
+}
+
+// fromGoValues converts the n Go values returned by value to mal.
+func fromGoValues(n int, value func(int) reflect.Value) ([]MalType, error) {
+	vals := make([]MalType, n)
+	for i := range vals {
+		val, err := fromGo(value(i))
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
+func conversionError(val MalType, t reflect.Type) error {
+	return fmt.Errorf("cannot convert %v to %v", TypeName(val), t)
+}
+
+// toGo converts a mal value to the Go type t.
+func toGo(val MalType, t reflect.Type) (reflect.Value, error) {
+	if mg, ok := val.(MalGo); ok {
+		v := reflect.ValueOf(mg.Value)
+		switch {
+		case mg.Value == nil:
+			return reflect.Zero(t), nil
+		case v.Type().AssignableTo(t):
+			return v, nil
+		case v.Type().ConvertibleTo(t):
+			return v.Convert(t), nil
+		}
+		return reflect.Value{}, conversionError(val, t)
+	}
+	if t == malType {
+		return reflect.ValueOf(&val).Elem(), nil
+	}
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		var plain interface{} = plainGo(val)
+		return reflect.ValueOf(&plain).Elem().Convert(t), nil
+	}
+	if val != nil && reflect.TypeOf(val).AssignableTo(t) {
+		return reflect.ValueOf(val), nil
+	}
+	if IsNil(val) {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(t), nil
+		}
+	}
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		v.SetBool(IsTruthy(val))
+		return v, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := intValue(val)
+		if !ok || v.OverflowInt(int64(n)) {
+			break
+		}
+		v.SetInt(int64(n))
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := intValue(val)
+		if !ok || n < 0 || v.OverflowUint(uint64(n)) {
+			break
+		}
+		v.SetUint(uint64(n))
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := intValue(val)
+		if !ok {
+			break
+		}
+		v.SetFloat(float64(n))
+		return v, nil
+	case reflect.String:
+		switch val := val.(type) {
+		case MalString:
+			v.SetString(val.Value)
+			return v, nil
+		case MalKeyword:
+			v.SetString(val.Value)
+			return v, nil
+		case MalSymbol:
+			v.SetString(val.Value)
+			return v, nil
+		}
+	case reflect.Slice:
+		if str, ok := val.(MalString); ok && t.Elem().Kind() == reflect.Uint8 {
+			return reflect.ValueOf([]byte(str.Value)).Convert(t), nil
+		}
+		vals, err := GetSlice(val)
+		if err != nil {
+			break
+		}
+		v = reflect.MakeSlice(t, len(vals), len(vals))
+		for i, elem := range vals {
+			e, err := toGo(elem, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(i).Set(e)
+		}
+		return v, nil
+	case reflect.Array:
+		vals, err := GetSlice(val)
+		if err != nil || len(vals) != t.Len() {
+			break
+		}
+		for i, elem := range vals {
+			e, err := toGo(elem, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(i).Set(e)
+		}
+		return v, nil
+	case reflect.Map:
+		m, err := GetMap(val)
+		if err != nil {
+			break
+		}
+		v = reflect.MakeMapWithSize(t, len(m.Value))
+		for key, elem := range m.Value {
+			k, err := toGo(KeyValue(key), t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			e, err := toGo(elem, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.SetMapIndex(k, e)
+		}
+		return v, nil
+	case reflect.Ptr:
+		elem, err := toGo(val, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v = reflect.New(t.Elem())
+		v.Elem().Set(elem)
+		return v, nil
+	case reflect.Func:
+		fn, err := GetFn(val)
+		if err != nil {
+			break
+		}
+		return makeFunc(fn, t), nil
+	}
+	return reflect.Value{}, conversionError(val, t)
+}
+
+func intValue(val MalType) (int, bool) {
+	switch val := val.(type) {
+	case MalInt:
+		return val.Value, true
+	case MalChar:
+		return int(val.Value), true
+	}
+	return 0, false
+}
+
+// plainGo converts a mal value to the Go value it naturally corresponds to, for arguments of type interface{}.
+// Hash-map keys which would become values Go cannot compare, such as slices, become their printed form instead.
+func plainGo(val MalType) interface{} {
+	switch val := val.(type) {
+	case MalNil:
+		return nil
+	case MalBool:
+		return val.Value
+	case MalInt:
+		return val.Value
+	case MalString:
+		return val.Value
+	case MalKeyword:
+		return val.Value
+	case MalChar:
+		return val.Value
+	case MalGo:
+		return val.Value
+	case MalMap:
+		m := make(map[interface{}]interface{}, len(val.Value))
+		for key, elem := range val.Value {
+			k := plainGo(KeyValue(key))
+			if k != nil && !reflect.ValueOf(k).Comparable() {
+				k = Print(KeyValue(key), true)
+			}
+			m[k] = plainGo(elem)
+		}
+		return m
+	default:
+		if vals, err := GetSlice(val); err == nil {
+			plain := make([]interface{}, len(vals))
+			for i, elem := range vals {
+				plain[i] = plainGo(elem)
+			}
+			return plain
+		}
+		return val
+	}
+}
+
+// callbackError carries the error of a mal callback out of a Go function which has no error result to return it
+// with, as a panic which callGo recovers.
+type callbackError struct {
+	err error
+}
+
+// callGo calls a Go function with mal arguments. A trailing error result is returned as the error and the other
+// results are converted to mal: none is nil, one is returned as it is and several are returned as a vector. A
+// panic in the function, including one carrying the error of a mal callback, is returned as an error rather than
+// ending the program.
+func callGo(fn reflect.Value, args []MalType) (res MalType, err error) {
+	t := fn.Type()
+	n := t.NumIn()
+	if t.IsVariadic() && len(args) < n-1 || !t.IsVariadic() && len(args) != n {
+		return nil, fmt.Errorf("%v invalid args: %v", t, args)
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var argType reflect.Type
+		if t.IsVariadic() && i >= n-1 {
+			argType = t.In(n - 1).Elem()
+		} else {
+			argType = t.In(i)
+		}
+		v, err := toGo(arg, argType)
+		if err != nil {
+			return nil, err
+		}
+		in[i] = v
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if cb, ok := r.(callbackError); ok {
+				res, err = nil, cb.err
+			} else {
+				res, err = nil, fmt.Errorf("%v panicked: %v", t, r)
+			}
+		}
+	}()
+	out := fn.Call(in)
+	if len(out) > 0 && t.Out(len(out)-1) == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return nil, err.Interface().(error)
+		}
+		out = out[:len(out)-1]
+	}
+	switch len(out) {
+	case 0:
+		return MalNil{}, nil
+	case 1:
+		return fromGo(out[0])
+	default:
+		vals, err := fromGoValues(len(out), func(i int) reflect.Value { return out[i] })
+		if err != nil {
+			return nil, err
+		}
+		return NewVec(vals), nil
+	}
+}
+
+func wrapFunc(fn reflect.Value) func([]MalType) (MalType, error) {
+	if f, ok := fn.Interface().(func([]MalType) (MalType, error)); ok {
+		return f
+	}
+	return func(args []MalType) (MalType, error) {
+		return callGo(fn, args)
+	}
+}
+
+// makeFunc creates a Go function of type t which calls a mal function. Its results are converted from the
+// result of fn, which must be a vector when there are several. If t has no trailing error result, errors panic
+// with a callbackError, which callGo turns back into the error when the Go function was called from mal.
+func makeFunc(fn func([]MalType) (MalType, error), t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, t.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(t.Out(i))
+		}
+		fail := func(err error) []reflect.Value {
+			if len(out) == 0 || t.Out(len(out)-1) != errorType {
+				panic(callbackError{err})
+			}
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			return out
+		}
+		args, err := fromGoValues(len(in), func(i int) reflect.Value { return in[i] })
+		if err != nil {
+			return fail(err)
+		}
+		res, err := fn(args)
+		if err != nil {
+			return fail(err)
+		}
+		results := out
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			results = out[:len(out)-1]
+		}
+		vals := []MalType{res}
+		if len(results) > 1 {
+			if vals, err = GetSlice(res); err != nil || len(vals) != len(results) {
+				return fail(fmt.Errorf("expected %d results: %v", len(results), res))
+			}
+		}
+		for i := range results {
+			v, err := toGo(vals[i], t.Out(i))
+			if err != nil {
+				return fail(err)
+			}
+			results[i] = v
+		}
+		return out
+	})
+}
+
+// WrapFunc converts a Go function into a builtin which converts its arguments and results between Go and mal.
+func WrapFunc(fn interface{}) (func([]MalType) (MalType, error), error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot wrap %T as a function", fn)
+	}
+	return wrapFunc(v), nil
+}
+
+// ToMal converts a Go value to mal. Functions are wrapped as builtins and values without a mal equivalent, such
+// as structs, are wrapped so that their methods and fields can be used with the . special form.
+func ToMal(val interface{}) (MalType, error) {
+	return fromGo(reflect.ValueOf(val))
+}
+
+// member calls the method of obj called name with args, or returns the value of the field called name when obj
+// has no such method. Only wrapped Go values have members, so that mal's own values, such as atoms, can only be
+// changed through the builtins which enforce their rules.
+func member(obj MalType, name string, args []MalType) (MalType, error) {
+	mg, ok := obj.(MalGo)
+	if !ok {
+		return nil, fmt.Errorf("cannot access members of %v", TypeName(obj))
+	}
+	v := reflect.ValueOf(mg.Value)
+	if !v.IsValid() {
+		return nil, errors.New("cannot access members of nil")
+	}
+	if method := v.MethodByName(name); method.IsValid() {
+		return callGo(method, args)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, fmt.Errorf("no method %s on %v", name, v.Type())
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if field, ok := v.Type().FieldByName(name); ok && field.PkgPath == "" {
+			if len(args) != 0 {
+				return nil, fmt.Errorf("field %s takes no args: %v", name, args)
+			}
+			return fromGo(v.FieldByIndex(field.Index))
+		}
+	}
+	return nil, fmt.Errorf("no method or field %s on %v", name, v.Type())
+}
@@ -0,0 +1,117 @@
+package mal
+
+import (
+	"fmt"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func (p *point) Add(q *point) *point {
+	return &point{X: p.X + q.X, Y: p.Y + q.Y}
+}
+
+func (p *point) Scale(k int) {
+	p.X *= k
+	p.Y *= k
+}
+
+func parsePoint(str string) (*point, error) {
+	var p point
+	if _, err := fmt.Sscanf(str, "%d,%d", &p.X, &p.Y); err != nil {
+		return nil, fmt.Errorf("bad point %q", str)
+	}
+	return &p, nil
+}
+
+// pointInterpreter returns an interpreter with Go functions on points, and taking or calling functions, defined.
+func pointInterpreter() *Interpreter {
+	in := NewInterpreter(Options{})
+	in.DefineGo("go-point", func(x, y int) *point {
+		return &point{X: x, Y: y}
+	})
+	in.DefineGo("go-parse-point", parsePoint)
+	in.DefineGo("go-twice", func(f func(int) int, x int) int {
+		return f(f(x))
+	})
+	in.DefineGo("go-try-twice", func(f func(int) (int, error), x int) (int, error) {
+		y, err := f(x)
+		if err != nil {
+			return 0, err
+		}
+		return f(y)
+	})
+	in.DefineGo("go-panic", func(msg string) {
+		panic(msg)
+	})
+	return in
+}
+
+func TestGoValues(t *testing.T) {
+	in := pointInterpreter()
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{`(go-point 1 2)`, `#<*mal.point &{1 2}>`},
+		{`(let* [p (go-point 1 2)] (list (. p X) (. p Y)))`, `(1 2)`},
+		{`(. (go-point 1 2) Add (go-point 3 4))`, `#<*mal.point &{4 6}>`},
+		{`(let* [p (go-point 1 2)] (do (. p Scale 10) (. p X)))`, `10`},
+		{`(go-parse-point "5,6")`, `#<*mal.point &{5 6}>`},
+		{`(try* (go-parse-point "x") (catch* exc exc))`, `"bad point \"x\""`},
+		{`(go-twice (fn* (x) (* x 3)) 2)`, `18`},
+		{`(try* (go-twice (fn* (x) (throw {:bad x})) 4) (catch* exc (get exc :bad)))`, `4`},
+		{`(go-try-twice (fn* (x) (+ x 1)) 1)`, `3`},
+		{`(try* (go-try-twice (fn* (x) (throw "no")) 1) (catch* exc exc))`, `"no"`},
+		{`(try* (go-panic "boom") (catch* exc exc))`, `"func(string) panicked: boom"`},
+	} {
+		if got := evalPrint(t, in, tc.src); got != tc.want {
+			t.Errorf("%s gave %s, want %s", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestGoValueErrors(t *testing.T) {
+	in := pointInterpreter()
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{`(. (go-point 1 2) Nope)`, `no method or field Nope on mal.point`},
+		{`(. (go-point 1 2) Scale)`, `func(int) invalid args: []`},
+	} {
+		if _, err := in.EvalString(tc.src); err == nil || err.Error() != tc.want {
+			t.Errorf("%s gave error %v, want %s", tc.src, err, tc.want)
+		}
+	}
+}
+
+func TestGoMapKeys(t *testing.T) {
+	in := NewInterpreter(Options{})
+	if err := in.DefineGo("counts", map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := evalPrint(t, in, `(get counts "a")`); got != `1` {
+		t.Errorf("converted map gave %s", got)
+	}
+	if err := in.DefineGo("grid", map[[2]int]int{{1, 2}: 3}); err != nil {
+		t.Fatal(err)
+	}
+	in.DefineGo("go-grid", func() map[[2]int]int {
+		return map[[2]int]int{{1, 2}: 3, {3, 4}: 5}
+	})
+	in.DefineGo("go-sum", func(m map[[2]int]int) int {
+		return m[[2]int{1, 2}] + m[[2]int{3, 4}]
+	})
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{`(get grid [1 2])`, `3`},
+		{`(get (go-grid) (list 3 4))`, `5`},
+		{`(go-sum (go-grid))`, `8`},
+	} {
+		if got := evalPrint(t, in, tc.src); got != tc.want {
+			t.Errorf("%s gave %s, want %s", tc.src, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package mal embeds the jvzgo interpreter in Go programs. Each Interpreter has its own global environment, so
+// several of them can be used independently in the same process.
+package mal
+
+import (
+	"core"
+	. "env"
+	"io"
+	"io/ioutil"
+	"reader"
+	"sync"
+	. "types"
+)
+
+// prelude defines the functions and macros which are written in mal itself.
+var prelude = []string{
+	`(def! not "Returns true if the argument is false or nil." (fn* (a) (if a false true)))`,
+	`(defmacro! cond "Evaluates the expression following the first truthy test." (fn* (& xs) (if (> (count xs) 0) (list 'if (first xs) (if (> (count xs) 1) (nth xs 1) (throw "odd number of forms to cond")) (cons 'cond (rest (rest xs)))))))`,
+	"(def! *gensym-counter* (atom 0))",
+	"(def! gensym \"Returns a new unique symbol.\" (fn* [] (symbol (str \"G__\" (swap! *gensym-counter* (fn* [x] (+ 1 x)))))))",
+	"(defmacro! or \"Returns the first truthy argument, evaluating no further, or the last.\" (fn* (& xs) (if (empty? xs) nil (if (= 1 (count xs)) (first xs) (let* (condvar (gensym)) `(let* (~condvar ~(first xs)) (if ~condvar ~condvar (or ~@(rest xs)))))))))",
+	"(defmacro! lazy-seq \"Returns a lazy sequence of the collection the body evaluates to on first use.\" (fn* (& body) `(lazy-seq* (fn* [] (do ~@body)))))",
+	"(defmacro! future \"Evaluates the body on another goroutine and returns a future for its value.\" (fn* (& body) `(future-call (fn* [] (do ~@body)))))",
+	"(defmacro! go \"Evaluates the body on a new goroutine and returns a channel receiving its value.\" (fn* (& body) `(go-call (fn* [] (do ~@body)))))",
+	"(defmacro! dosync \"Evaluates the body in a transaction, retrying it on conflicts.\" (fn* (& body) `(dosync-call (fn* [] (do ~@body)))))",
+	"(defmacro! with-open \"Binds names to streams, evaluates the body and closes the streams in reverse order.\" (fn* (bindings & body) (if (empty? bindings) `(do ~@body) `(with-open-call ~(nth bindings 1) (fn* [~(first bindings)] (with-open ~(rest (rest bindings)) ~@body))))))",
+	"(defmacro! defn \"Defines a function, with an optional docstring and attribute map before its parameters.\" (fn* (name & decl) (let* [doc (if (string? (first decl)) [(first decl)] []) decl (if (string? (first decl)) (rest decl) decl) attrs (if (map? (first decl)) [(first decl)] []) decl (if (map? (first decl)) (rest decl) decl)] `(def! ~name ~@doc ~@attrs (fn* ~(first decl) (do ~@(rest decl)))))))",
+}
+
+// Version is the version of jvzgo.
+const Version = "1.0.0"
+
+// Options configure a new Interpreter.
+type Options struct {
+	// Args are the command line arguments bound to *ARGV*.
+	Args []string
+}
+
+// Interpreter evaluates mal code in its own global environment.
+type Interpreter struct {
+	env EnvType
+	// sources holds the reader.Form which last defined each name.
+	sources sync.Map
+}
+
+func NewInterpreter(opts Options) *Interpreter {
+	in := &Interpreter{env: NewEnv()}
+	for sym, fn := range core.NS {
+		in.defineBuiltin(sym, core.Docs[sym], fn)
+	}
+	in.defineBuiltin("eval", "Evaluates a form in the global environment.", core.MonoErrFunc(func(a MalType) (MalType, error) {
+		return Eval(a, in.env)
+	}))
+	in.defineBuiltin("load-file", "Evaluates every form in a file and returns the value of the last one.", core.MonoErrFunc(func(a MalType) (MalType, error) {
+		path, err := GetString(a)
+		if err != nil {
+			return nil, err
+		}
+		return in.LoadFile(path.Value)
+	}))
+	in.env.Set("*host-language*", MalString{Value: "jvzgo"})
+	in.env.Set("*in*", core.Stdin)
+	in.env.Set("*out*", core.Stdout)
+	in.env.Set("*err*", core.Stderr)
+	argv := make([]MalType, len(opts.Args))
+	for i, arg := range opts.Args {
+		argv[i] = MalString{Value: arg}
+	}
+	in.env.Set("*ARGV*", NewList(argv))
+	in.defineHelp()
+	for _, src := range append(prelude, helpPrelude...) {
+		if _, err := in.EvalString(src); err != nil {
+			panic(err)
+		}
+	}
+	return in
+}
+
+// Env returns the global environment of the interpreter.
+func (in *Interpreter) Env() EnvType {
+	return in.env
+}
+
+// Symbols lists the symbols bound in the global environment.
+func (in *Interpreter) Symbols() []string {
+	return in.env.(*Env).Symbols()
+}
+
+// Eval evaluates a form in the global environment.
+func (in *Interpreter) Eval(ast MalType) (MalType, error) {
+	return Eval(ast, in.env)
+}
+
+// EvalString reads and evaluates every form in src, returning the value of the last one.
+func (in *Interpreter) EvalString(src string) (MalType, error) {
+	return in.evalSource(src, "")
+}
+
+// evalSource evaluates every form in src, the contents of file, returning the value of the last one.
+func (in *Interpreter) evalSource(src, file string) (MalType, error) {
+	var res MalType = MalNil{}
+	forms, err := reader.ReadSource(src, file)
+	if err != nil {
+		return nil, err
+	}
+	for _, form := range forms {
+		if res, err = in.EvalForm(form); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// EvalReader reads r to the end and evaluates every form in it, returning the value of the last one.
+func (in *Interpreter) EvalReader(r io.Reader) (MalType, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return in.EvalString(string(src))
+}
+
+// LoadFile evaluates every form in a file, returning the value of the last one.
+func (in *Interpreter) LoadFile(path string) (MalType, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return in.evalSource(string(src), path)
+}
+
+// defineBuiltin binds name to a builtin with doc as the docstring of the binding.
+func (in *Interpreter) defineBuiltin(name, doc string, fn MalType) {
+	if f, ok := fn.(func([]MalType) (MalType, error)); ok {
+		fn = NewFn(f)
+	}
+	in.env.Set(name, fn)
+	meta := map[MalType]MalType{MalKeyword{Value: "name"}: MalSymbol{Value: name}}
+	if doc != "" {
+		meta[MalKeyword{Value: "doc"}] = MalString{Value: doc}
+	}
+	in.env.SetMeta(name, MalMap{Value: meta})
+}
+
+// Define binds name to val in the global environment. Like def!, it replaces the metadata of any earlier binding
+// of name, such as its docstring, and forgets its source.
+func (in *Interpreter) Define(name string, val MalType) {
+	sym := MalSymbol{Value: name}
+	define(in.env, sym, val, map[MalType]MalType{MalKeyword{Value: "name"}: sym})
+	in.sources.Delete(name)
+}
+
+// RegisterFunc binds name to a builtin function implemented in Go, as Define does.
+func (in *Interpreter) RegisterFunc(name string, fn func([]MalType) (MalType, error)) {
+	in.Define(name, NewFn(fn))
+}
+
+// DefineGo binds name to a Go value converted to mal. Functions become builtins which convert their arguments
+// and results, and structs and pointers can be used with the . special form. It returns the error of ToMal and
+// leaves name unbound if the value cannot be converted.
+func (in *Interpreter) DefineGo(name string, val interface{}) error {
+	res, err := ToMal(val)
+	if err != nil {
+		return err
+	}
+	in.Define(name, res)
+	return nil
+}
+
+// Call calls a mal function or builtin with the given arguments.
+func (in *Interpreter) Call(fn MalType, args ...MalType) (MalType, error) {
+	f, err := GetFn(fn)
+	if err != nil {
+		return nil, err
+	}
+	return f(args)
+}
@@ -0,0 +1,231 @@
+package mal
+
+import (
+	"errors"
+	"printer"
+	"strings"
+	"sync"
+	"testing"
+	. "types"
+)
+
+// evalPrint evaluates src and returns the printed value of the last form.
+func evalPrint(t *testing.T, in *Interpreter, src string) string {
+	t.Helper()
+	res, err := in.EvalString(src)
+	if err != nil {
+		t.Fatalf("%s: %v", src, err)
+	}
+	return printer.PrintStr(res, true)
+}
+
+func TestEvalString(t *testing.T) {
+	in := NewInterpreter(Options{Args: []string{"a", "b"}})
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{``, `nil`},
+		{`(+ 1 2)`, `3`},
+		{`(def! x 2) (* x 5)`, `10`},
+		{`x`, `2`},
+		{`(defn twice [f v] (f (f v))) (twice (fn* [n] (* n 3)) 1)`, `9`},
+		{`*ARGV*`, `("a" "b")`},
+		{`*host-language*`, `"jvzgo"`},
+	} {
+		if got := evalPrint(t, in, tc.src); got != tc.want {
+			t.Errorf("%s gave %s, want %s", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestEvalStringErrors(t *testing.T) {
+	in := NewInterpreter(Options{})
+	if _, err := in.EvalString(`(+ 1`); err == nil {
+		t.Error("unbalanced input was read")
+	}
+	if _, err := in.EvalString(`(undefined-fn 1)`); err == nil || !strings.Contains(err.Error(), "'undefined-fn' not found") {
+		t.Errorf("calling an unbound symbol gave error %v", err)
+	}
+	_, err := in.EvalString(`(throw {:code 7})`)
+	var malErr MalError
+	if !errors.As(err, &malErr) {
+		t.Fatalf("throw gave error %v, want a MalError", err)
+	}
+	if got := printer.PrintStr(malErr.Value, true); got != `{:code 7}` {
+		t.Errorf("thrown value %s", got)
+	}
+	if _, err := in.EvalString(`(def! y 1) (throw "stop") (def! z 2)`); err == nil {
+		t.Error("throw did not stop evaluation")
+	}
+	if got := evalPrint(t, in, `(list y (try* z (catch* exc :unbound)))`); got != `(1 :unbound)` {
+		t.Errorf("forms around a throw gave %s", got)
+	}
+}
+
+func TestEvalReader(t *testing.T) {
+	in := NewInterpreter(Options{})
+	res, err := in.EvalReader(strings.NewReader("(def! n 4)\n(* n n)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(res, MalInt{Value: 16}) {
+		t.Errorf("EvalReader gave %v", printer.PrintStr(res, true))
+	}
+}
+
+func TestDefineAndCall(t *testing.T) {
+	in := NewInterpreter(Options{})
+	in.Define("limit", MalInt{Value: 3})
+	in.RegisterFunc("shout", func(args []MalType) (MalType, error) {
+		s, err := GetString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return MalString{Value: strings.ToUpper(s.Value) + "!"}, nil
+	})
+	if got := evalPrint(t, in, `(list limit (shout "hi"))`); got != `(3 "HI!")` {
+		t.Errorf("defined values gave %s", got)
+	}
+	fn, err := in.EvalString(`(fn* [a b] (- a b))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := in.Call(fn, MalInt{Value: 10}, MalInt{Value: 4})
+	if err != nil || !Equal(res, MalInt{Value: 6}) {
+		t.Errorf("Call gave %v, %v", res, err)
+	}
+	if _, err := in.Call(MalInt{Value: 1}); err == nil {
+		t.Error("called a number")
+	}
+}
+
+type counter struct {
+	N int
+}
+
+func (c *counter) Add(n int) int {
+	c.N += n
+	return c.N
+}
+
+func TestDefineGo(t *testing.T) {
+	in := NewInterpreter(Options{})
+	c := &counter{}
+	in.DefineGo("counter", c)
+	in.DefineGo("repeat-str", strings.Repeat)
+	in.DefineGo("names", []string{"x", "y"})
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{`(repeat-str "ab" 3)`, `"ababab"`},
+		{`names`, `["x" "y"]`},
+		{`(. counter Add 2)`, `2`},
+		{`(. counter Add 5)`, `7`},
+		{`(. counter N)`, `7`},
+	} {
+		if got := evalPrint(t, in, tc.src); got != tc.want {
+			t.Errorf("%s gave %s, want %s", tc.src, got, tc.want)
+		}
+	}
+	if c.N != 7 {
+		t.Errorf("counter is %d after calls from mal", c.N)
+	}
+	if _, err := in.EvalString(`(repeat-str "ab")`); err == nil {
+		t.Error("called a Go function with too few arguments")
+	}
+}
+
+func TestInterpretersAreIsolated(t *testing.T) {
+	a := NewInterpreter(Options{Args: []string{"a"}})
+	b := NewInterpreter(Options{})
+	evalPrint(t, a, `(def! shared 1) (defn helper [] :a)`)
+	a.Define("defined", MalInt{Value: 2})
+	for _, sym := range []string{"shared", "helper", "defined"} {
+		if _, err := b.EvalString(sym); err == nil {
+			t.Errorf("%s defined in one interpreter is bound in another", sym)
+		}
+	}
+	evalPrint(t, b, `(def! not (fn* [x] :redefined))`)
+	if got := evalPrint(t, a, `(not true)`); got != `false` {
+		t.Errorf("redefining not in one interpreter changed another: %s", got)
+	}
+	if got := evalPrint(t, b, `*ARGV*`); got != `()` {
+		t.Errorf("*ARGV* of one interpreter is %s", got)
+	}
+}
+
+func TestInterpretersRunConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	results := make([]MalType, 4)
+	errs := make([]error, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			in := NewInterpreter(Options{})
+			in.Define("id", MalInt{Value: i})
+			results[i], errs[i] = in.EvalString(`(def! total (atom 0)) (reduce (fn* [_ n] (swap! total (fn* [x] (+ x (+ id n))))) nil (range 100)) @total`)
+		}(i)
+	}
+	wg.Wait()
+	for i, res := range results {
+		if want := (MalInt{Value: 100*i + 4950}); errs[i] != nil || !Equal(res, want) {
+			t.Errorf("interpreter %d gave %v, %v; want %v", i, res, errs[i], want)
+		}
+	}
+}
+
+func TestDefineReplacesMetadata(t *testing.T) {
+	in := NewInterpreter(Options{})
+	evalPrint(t, in, `(defn limit "The old limit." {:added 1} [] 3)`)
+	if _, ok := in.Source("limit"); !ok {
+		t.Fatal("no source kept for defn")
+	}
+	in.Define("limit", MalInt{Value: 4})
+	if got := evalPrint(t, in, `(meta (var limit))`); got != `{:name limit}` {
+		t.Errorf("metadata after Define is %s", got)
+	}
+	if _, ok := in.Source("limit"); ok {
+		t.Error("the source of the old definition was kept")
+	}
+	in.DefineGo("limit", func(a, b int) int { return a + b })
+	if got := evalPrint(t, in, `(list (limit 1 2) (meta (var limit)))`); got != `(3 {:name limit})` {
+		t.Errorf("DefineGo gave %s", got)
+	}
+	fn, err := in.EvalString(`(fn* [x y] x)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Define("pick", fn)
+	if got := evalPrint(t, in, `(get (meta (var pick)) :arglists)`); got != `([x y])` {
+		t.Errorf("arglists of a defined function are %s", got)
+	}
+}
+
+// TestSharedFramesInFutures shares local frames and closures between futures while def! and let* add bindings to
+// them, and is meant to be run with -race.
+func TestSharedFramesInFutures(t *testing.T) {
+	in := NewInterpreter(Options{})
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{`(let* [a 1] (do (def! f1 (future (reduce + 0 (map (fn* [i] (+ i a)) (range 2000))))) (def! q1 1) @f1))`, `2001000`},
+		{`(let* [x 1 f (future (+ x 1)) y 2] (+ @f y))`, `4`},
+		{`((fn* [n] (let* [fs (map (fn* [i] (future (do (def! seen i) (+ i n)))) (range 8)) _ (def! mid n)] (reduce + 0 (map deref fs)))) 10)`, `108`},
+		{`(let* [add (fn* [a] (fn* [b] (+ a b))) inc5 (add 5) fs (map (fn* [i] (future (inc5 i))) (range 8))] (reduce + 0 (map deref fs)))`, `68`},
+		{`(do (def! hits (atom 0)) (let* [bump (fn* [] (swap! hits (fn* [h] (+ h 1))))] (count (pmap (fn* [_] (do (def! local _) (bump))) (range 50)))) @hits)`, `50`},
+	} {
+		if got := evalPrint(t, in, tc.src); got != tc.want {
+			t.Errorf("%s gave %s, want %s", tc.src, got, tc.want)
+		}
+	}
+}
+
+// TestSharedLazySeq realizes one lazy seq from several futures at once, and is meant to be run with -race.
+func TestSharedLazySeq(t *testing.T) {
+	in := NewInterpreter(Options{})
+	src := `(let* [xs (map (fn* [x] (* x 2)) (range 1000)) fs (into [] (map (fn* [_] (future (reduce + 0 xs))) (range 4)))] (list (reduce + 0 (map deref fs)) (count xs)))`
+	if got := evalPrint(t, in, src); got != `(3996000 1000)` {
+		t.Errorf("futures sharing a lazy seq gave %s", got)
+	}
+}
@@ -0,0 +1,324 @@
+package mal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	. "types"
+)
+
+// Marshaler is implemented by Go types which convert themselves to mal.
+type Marshaler interface {
+	MarshalMal() (MalType, error)
+}
+
+// Unmarshaler is implemented by Go types which set themselves from a mal value.
+type Unmarshaler interface {
+	UnmarshalMal(MalType) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	durationType    = reflect.TypeOf(time.Duration(0))
+)
+
+// field describes how a struct field is stored in a mal map.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // reflect.Type -> []field
+
+// structFields lists the fields of a struct type which are marshalled. Like encoding/json, a field is stored under
+// its name unless a `mal:"name"` tag renames it, `mal:"-"` skips it and the omitempty option leaves it out of maps
+// when it has its zero value. The fields of embedded structs without a tag are promoted.
+func structFields(t reflect.Type) []field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("mal")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			for _, inner := range structFields(f.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts := tag, ""
+		if comma := strings.IndexByte(tag, ','); comma >= 0 {
+			name, opts = tag[:comma], tag[comma+1:]
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, field{name: name, index: []int{i}, omitEmpty: opts == "omitempty"})
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// findField looks up the field stored under name, preferring an exact match to a case-insensitive one.
+func findField(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+// Marshal converts a Go value to mal data. Structs become maps from keywords to their field values, durations
+// become strings such as "1m30s" and values implementing Marshaler convert themselves. Floating-point and complex
+// numbers, which mal has no equivalent of, are errors. Everything else is converted as for ToMal.
+func Marshal(val interface{}) (MalType, error) {
+	return marshal(reflect.ValueOf(val))
+}
+
+func marshal(v reflect.Value) (MalType, error) {
+	if !v.IsValid() {
+		return MalNil{}, nil
+	}
+	if v.Type().Implements(marshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return MalNil{}, nil
+		}
+		return v.Interface().(Marshaler).MarshalMal()
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(marshalerType) {
+		return v.Addr().Interface().(Marshaler).MarshalMal()
+	}
+	if v.Type() == durationType {
+		return MalString{Value: time.Duration(v.Int()).String()}, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return marshal(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() || v.Type().Elem().PkgPath() == typesPath {
+			return fromGo(v)
+		}
+		return marshal(v.Elem())
+	case reflect.Struct:
+		if v.Type().PkgPath() == typesPath {
+			return fromGo(v)
+		}
+		m := make(map[MalType]MalType)
+		for _, f := range structFields(v.Type()) {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			val, err := marshal(fv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			m[MalKeyword{Value: f.name}] = val
+		}
+		return MalMap{Value: m}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return MalNil{}, nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return MalString{Value: string(v.Bytes())}, nil
+		}
+		vals := make([]MalType, v.Len())
+		for i := range vals {
+			val, err := marshal(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = val
+		}
+		return NewVec(vals), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return MalNil{}, nil
+		}
+		m := make(map[MalType]MalType, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := marshal(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			if key, err = MapKey(key); err != nil {
+				return nil, err
+			}
+			val, err := marshal(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[key] = val
+		}
+		return MalMap{Value: m}, nil
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return nil, fmt.Errorf("cannot marshal %v: mal has no %v numbers", v.Type(), v.Kind())
+	default:
+		return fromGo(v)
+	}
+}
+
+// Unmarshal stores mal data in the Go value pointed to by ptr, reversing Marshal. Map keys may be keywords or
+// strings and are matched to struct fields as by encoding/json; keys without a field are ignored. Durations may be
+// given as strings such as "1m30s" or as integer milliseconds.
+func Unmarshal(val MalType, ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("cannot unmarshal into %T", ptr)
+	}
+	return unmarshal(val, v.Elem())
+}
+
+func unmarshal(val MalType, v reflect.Value) error {
+	if v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		return v.Addr().Interface().(Unmarshaler).UnmarshalMal(val)
+	}
+	if v.Type() == durationType {
+		switch d := val.(type) {
+		case MalString:
+			parsed, err := time.ParseDuration(d.Value)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(parsed))
+			return nil
+		case MalInt:
+			v.SetInt(int64(time.Duration(d.Value) * time.Millisecond))
+			return nil
+		}
+		return conversionError(val, v.Type())
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if IsNil(val) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshal(val, v.Elem())
+	case reflect.Struct:
+		if IsGo(val) {
+			break
+		}
+		m, err := GetMap(val)
+		if err != nil {
+			return conversionError(val, v.Type())
+		}
+		fields := structFields(v.Type())
+		for key, elem := range m.Value {
+			var name string
+			switch key := KeyValue(key).(type) {
+			case MalKeyword:
+				name = key.Value
+			case MalString:
+				name = key.Value
+			default:
+				continue
+			}
+			f, ok := findField(fields, name)
+			if !ok {
+				continue
+			}
+			if err := unmarshal(elem, v.FieldByIndex(f.index)); err != nil {
+				return fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if _, ok := val.(MalString); ok || IsNil(val) || IsGo(val) {
+			break
+		}
+		vals, err := GetSlice(val)
+		if err != nil {
+			return conversionError(val, v.Type())
+		}
+		s := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i, elem := range vals {
+			if err := unmarshal(elem, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Array:
+		if IsGo(val) {
+			break
+		}
+		vals, err := GetSlice(val)
+		if err != nil || len(vals) != v.Len() {
+			return conversionError(val, v.Type())
+		}
+		for i, elem := range vals {
+			if err := unmarshal(elem, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if IsNil(val) || IsGo(val) {
+			break
+		}
+		m, err := GetMap(val)
+		if err != nil {
+			return conversionError(val, v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(m.Value)))
+		}
+		for key, elem := range m.Value {
+			k := reflect.New(v.Type().Key()).Elem()
+			if err := unmarshal(KeyValue(key), k); err != nil {
+				return err
+			}
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := unmarshal(elem, e); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, e)
+		}
+		return nil
+	}
+	converted, err := toGo(val, v.Type())
+	if err != nil {
+		return err
+	}
+	if !v.CanSet() {
+		return errors.New("cannot unmarshal into unexported field")
+	}
+	v.Set(converted)
+	return nil
+}
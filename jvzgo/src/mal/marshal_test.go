@@ -0,0 +1,161 @@
+package mal
+
+import (
+	"printer"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	. "types"
+)
+
+type address struct {
+	City string `mal:"city"`
+	Zip  string `mal:"zip,omitempty"`
+}
+
+type person struct {
+	Name    string            `mal:"name"`
+	Age     int               `mal:"age"`
+	Tags    []string          `mal:"tags"`
+	Scores  map[string]int    `mal:"scores"`
+	Home    *address          `mal:"home"`
+	Timeout time.Duration     `mal:"timeout"`
+	Secret  string            `mal:"-"`
+	Extra   map[string]string `mal:"extra,omitempty"`
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	in := person{
+		Name:    "Ada",
+		Age:     36,
+		Tags:    []string{"math", "engines"},
+		Scores:  map[string]int{"a": 1, "b": 2},
+		Home:    &address{City: "London"},
+		Timeout: 90 * time.Second,
+		Secret:  "hidden",
+	}
+	val, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := GetMap(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"name", "age", "tags", "scores", "home", "timeout"} {
+		if _, ok := m.Value[MalKeyword{Value: key}]; !ok {
+			t.Errorf("marshalled %v has no :%s", printer.PrintStr(val, true), key)
+		}
+	}
+	for _, key := range []string{"Secret", "extra"} {
+		if _, ok := m.Value[MalKeyword{Value: key}]; ok {
+			t.Errorf("marshalled %v has :%s", printer.PrintStr(val, true), key)
+		}
+	}
+	if got := m.Value[MalKeyword{Value: "timeout"}]; !Equal(got, MalString{Value: "1m30s"}) {
+		t.Errorf("timeout marshalled as %v", printer.PrintStr(got, true))
+	}
+	home := m.Value[MalKeyword{Value: "home"}].(MalMap)
+	if _, ok := home.Value[MalKeyword{Value: "zip"}]; ok {
+		t.Errorf("empty zip was not omitted: %v", printer.PrintStr(home, true))
+	}
+
+	var out person
+	if err := Unmarshal(val, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Secret = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip gave %+v, want %+v", out, in)
+	}
+}
+
+func TestUnmarshalFromMal(t *testing.T) {
+	in := NewInterpreter(Options{})
+	val, err := in.EvalString(`{:name "Bob" "age" 7 :tags ["x"] :home {:city "Paris" :zip "75001"} :timeout 1500 :other 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p person
+	if err := Unmarshal(val, &p); err != nil {
+		t.Fatal(err)
+	}
+	want := person{Name: "Bob", Age: 7, Tags: []string{"x"}, Home: &address{City: "Paris", Zip: "75001"},
+		Timeout: 1500 * time.Millisecond}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("unmarshalled %+v, want %+v", p, want)
+	}
+}
+
+func TestMarshalSlicesAndMaps(t *testing.T) {
+	val, err := Marshal(map[string][]int{"evens": {2, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := printer.PrintStr(val, true); got != `{"evens" [2 4]}` {
+		t.Errorf("marshalled %s", got)
+	}
+	var out map[string][]int
+	if err := Unmarshal(val, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[string][]int{"evens": {2, 4}}) {
+		t.Errorf("unmarshalled %v", out)
+	}
+}
+
+func TestMarshalCollectionKeys(t *testing.T) {
+	val, err := Marshal(map[address]int{{City: "Rome"}: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := printer.PrintStr(val, true); got != `{{:city "Rome"} 1}` {
+		t.Errorf("marshalled %s", got)
+	}
+	var out map[address]int
+	if err := Unmarshal(val, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, map[address]int{{City: "Rome"}: 1}) {
+		t.Errorf("unmarshalled %v", out)
+	}
+	grid, err := Marshal(map[[2]int]bool{{1, 2}: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := GetMap(grid); len(got.Value) != 1 || printer.PrintStr(grid, true) != `{[1 2] true}` {
+		t.Errorf("marshalled %s", printer.PrintStr(grid, true))
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		val  interface{}
+		want string
+	}{
+		{0.5, "cannot marshal float64"},
+		{[]float32{1}, "cannot marshal float32"},
+		{struct {
+			Ratio float64 `mal:"ratio"`
+		}{}, "ratio: cannot marshal float64"},
+	} {
+		_, err := Marshal(tc.val)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Marshal(%#v) gave error %v, want %q", tc.val, err, tc.want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var p person
+	if err := Unmarshal(MalString{Value: "x"}, &p); err == nil {
+		t.Error("unmarshalled a string into a struct")
+	}
+	if err := Unmarshal(MalMap{Value: map[MalType]MalType{MalKeyword{Value: "age"}: MalString{Value: "old"}}}, &p); err == nil || !strings.HasPrefix(err.Error(), "age: ") {
+		t.Errorf("unmarshalling a string age gave error %v", err)
+	}
+	if err := Unmarshal(MalInt{Value: 1}, p); err == nil {
+		t.Error("unmarshalled into a non-pointer")
+	}
+}
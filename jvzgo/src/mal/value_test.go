@@ -0,0 +1,104 @@
+package mal
+
+import (
+	"fmt"
+	"printer"
+	"testing"
+	. "types"
+)
+
+// money is a value type defined outside the types package, which the interpreter supports through MalValue.
+type money struct {
+	cents int
+	meta  MalType
+}
+
+func (m money) String() string {
+	return m.Print(false)
+}
+
+func (m money) Print(readably bool) string {
+	amount := fmt.Sprintf("%d.%02d", m.cents/100, m.cents%100)
+	if readably {
+		return "#<money " + amount + ">"
+	}
+	return amount
+}
+
+func (m money) Equals(other MalType) bool {
+	b, ok := other.(money)
+	return ok && m.cents == b.cents
+}
+
+func (m money) Hash() uint64 {
+	return uint64(m.cents)
+}
+
+func (m money) Metadata() MalType {
+	return WrapNil(m.meta)
+}
+
+func (m money) WithMetadata(meta MalType) (MalType, error) {
+	return money{cents: m.cents, meta: meta}, nil
+}
+
+func (money) TypeName() string {
+	return "money"
+}
+
+func TestCustomValue(t *testing.T) {
+	in := NewInterpreter(Options{})
+	in.Define("price", money{cents: 150})
+	in.RegisterFunc("money", func(args []MalType) (MalType, error) {
+		cents, err := GetInt(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return money{cents: cents.Value}, nil
+	})
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{`(pr-str price)`, `"#<money 1.50>"`},
+		{`(str "cost: " price)`, `"cost: 1.50"`},
+		{`[price]`, `[#<money 1.50>]`},
+		{`(= price (money 150))`, `true`},
+		{`(= price (money 1))`, `false`},
+		{`(= [price] (list (money 150)))`, `true`},
+		{`(= (hash price) (hash (money 150)))`, `true`},
+		{`(type-of price)`, `"money"`},
+		{`(meta (with-meta price {:a 1}))`, `{:a 1}`},
+		{`(= price (with-meta price {:a 1}))`, `true`},
+		{`(get (hash-map price :paid) (money 150))`, `:paid`},
+		{`(count (distinct [price (money 150) (money 2)]))`, `2`},
+	} {
+		res, err := in.EvalString(tc.src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.src, err)
+			continue
+		}
+		if got := printer.PrintStr(res, true); got != tc.want {
+			t.Errorf("%s gave %s, want %s", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestCollectionKeys(t *testing.T) {
+	in := NewInterpreter(Options{})
+	in.Define("price", money{cents: 150, meta: MalMap{Value: map[MalType]MalType{MalKeyword{Value: "a"}: MalInt{Value: 1}}}})
+	in.Define("plain-price", money{cents: 150})
+	in.DefineGo("bag", struct{ Items []int }{Items: []int{1}})
+	in.DefineGo("same-bag", struct{ Items []int }{Items: []int{1}})
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{`(get (hash-map price 1) plain-price)`, `1`},
+		{`(get (assoc {} plain-price 1) price)`, `1`},
+		{`(get (hash-map bag 1) same-bag)`, `1`},
+		{`(contains? (hash-map [bag] 1) [same-bag])`, `true`},
+	} {
+		if got := evalPrint(t, in, tc.src); got != tc.want {
+			t.Errorf("%s gave %s, want %s", tc.src, got, tc.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	. "types"
+	"unicode/utf8"
 )
 
 func ReadStr(str string) (MalType, error) {
@@ -14,33 +15,105 @@ func ReadStr(str string) (MalType, error) {
 	return tr.ReadForm()
 }
 
+// ReadAll reads every form in str.
+func ReadAll(str string) ([]MalType, error) {
+	tr := NewReader(str)
+	var forms []MalType
+	for tr.peek() != nil {
+		form, err := tr.ReadForm()
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, form)
+	}
+	return forms, nil
+}
+
+// ErrIncomplete is matched, using errors.Is, by the errors returned when the input ends inside a form, so that a
+// REPL can tell that it should read another line rather than report the error.
+var ErrIncomplete = errors.New("incomplete input")
+
+// incompleteError keeps the message describing what was missing while matching ErrIncomplete.
+type incompleteError string
+
+func (e incompleteError) Error() string {
+	return string(e)
+}
+
+func (incompleteError) Is(target error) bool {
+	return target == ErrIncomplete
+}
+
+// Form is a top-level form read from source code, with where it was found.
+type Form struct {
+	Value MalType
+	File  string
+	// Line is the line at which the form starts, counting from 1.
+	Line int
+	// Text is the form as written.
+	Text string
+}
+
+// ReadSource reads every form in src, the contents of file, keeping their positions and text.
+func ReadSource(src, file string) ([]Form, error) {
+	tr := NewReader(src)
+	var forms []Form
+	line, counted := 1, 0
+	for tr.peek() != nil {
+		start := tr.spans[0][0]
+		form, err := tr.ReadForm()
+		if err != nil {
+			return nil, err
+		}
+		line += strings.Count(src[counted:start], "\n")
+		counted = start
+		forms = append(forms, Form{Value: form, File: file, Line: line, Text: src[start:tr.end]})
+	}
+	return forms, nil
+}
+
 type Reader interface {
 	ReadForm() (MalType, error)
 }
 
-var tokenPattern = regexp.MustCompile(`[\s,]*(~@|[\[\]{}()'` + "`" + `~^@]|"(?:\\.|[^\\"])*"|;.*|[^\s\[\]{}('"` + "`" + `,;)]*)`)
+var tokenPattern = regexp.MustCompile(`[\s,]*(~@|#'|[\[\]{}()'` + "`" + `~^@]|#?"(?:\\.|[^\\"])*"?|\\.[^\s\[\]{}('"` + "`" + `,;)]*|;.*|[^\s\[\]{}('"` + "`" + `,;)]*)`)
 
-func tokenizer(str string) []string {
+// tokenizer splits str into tokens, returning the offsets in str at which each one starts and ends.
+func tokenizer(str string) ([]string, [][2]int) {
 	tokens := make([]string, 0, 1)
-	for _, groups := range tokenPattern.FindAllStringSubmatch(str, -1) {
-		if groups[1] == "" || groups[1][0] == ';' {
+	spans := make([][2]int, 0, 1)
+	for _, loc := range tokenPattern.FindAllStringSubmatchIndex(str, -1) {
+		token := str[loc[2]:loc[3]]
+		if token == "" || token[0] == ';' {
 			// ignore comments and blank lines
 			continue
 		}
-		tokens = append(tokens, groups[1])
+		tokens = append(tokens, token)
+		spans = append(spans, [2]int{loc[2], loc[3]})
 	}
-	return tokens
+	return tokens, spans
 }
 
 func NewReader(text string) *TokenReader {
-	tokens := tokenizer(text)
-	reader := TokenReader{tokens: tokens}
+	if strings.HasPrefix(text, "#!") {
+		// skip the interpreter line of an executable script, keeping the offsets of the tokens after it
+		if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+			text = strings.Repeat(" ", nl) + text[nl:]
+		} else {
+			text = ""
+		}
+	}
+	tokens, spans := tokenizer(text)
+	reader := TokenReader{tokens: tokens, spans: spans}
 	return &reader
 }
 
 type TokenReader struct {
 	pos    uint
 	tokens []string
+	spans  [][2]int
+	// end is the offset of the end of the last token read.
+	end int
 }
 
 func (tr *TokenReader) next() *string {
@@ -48,7 +121,9 @@ func (tr *TokenReader) next() *string {
 		return nil
 	}
 	next := &tr.tokens[0]
+	tr.end = tr.spans[0][1]
 	tr.tokens = tr.tokens[1:]
+	tr.spans = tr.spans[1:]
 	return next
 }
 
@@ -91,7 +166,11 @@ func (tr *TokenReader) ReadForm() (MalType, error) {
 		}
 		m := make(map[MalType]MalType)
 		for i := 0; i < len(keyValues); i += 2 {
-			m[keyValues[i]] = keyValues[i+1]
+			key, err := MapKey(keyValues[i])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = keyValues[i+1]
 		}
 		return MalMap{Value: m}, nil
 	case "}":
@@ -102,6 +181,7 @@ func (tr *TokenReader) ReadForm() (MalType, error) {
 }
 
 var stringEscapesReplacer = strings.NewReplacer(`\"`, `"`, `\n`, "\n", `\\`, `\`)
+var stringPattern = regexp.MustCompile(`^#?"(?:\\.|[^\\"])*"$`)
 var intPattern = regexp.MustCompile(`^-?[0-9]+$`)
 
 func (tr *TokenReader) readAtom() (MalType, error) {
@@ -110,16 +190,19 @@ func (tr *TokenReader) readAtom() (MalType, error) {
 		return nil, errors.New("readAtom underflow")
 	}
 	switch {
+	case ((*tok)[0] == '"' || strings.HasPrefix(*tok, `#"`)) && !stringPattern.MatchString(*tok):
+		return nil, incompleteError(`expected "`)
 	case (*tok)[0] == '"':
-		end := strings.LastIndex(*tok, `"`)
-		if end <= 0 {
-			return nil, errors.New("unbalanced quotes")
-		}
-		contents := stringEscapesReplacer.Replace((*tok)[1:end])
+		contents := stringEscapesReplacer.Replace((*tok)[1 : len(*tok)-1])
 		return MalString{Value: contents}, nil
+	case strings.HasPrefix(*tok, `#"`):
+		// regex literals are taken verbatim so that backslashes need not be doubled
+		return NewRegex((*tok)[2 : len(*tok)-1])
 	case (*tok)[0] == ':':
 		keyword := (*tok)[1:]
 		return MalKeyword{Value: keyword}, nil
+	case (*tok)[0] == '\\':
+		return readChar((*tok)[1:])
 	case intPattern.MatchString(*tok):
 		i, err := strconv.Atoi(*tok)
 		if err != nil {
@@ -129,12 +212,24 @@ func (tr *TokenReader) readAtom() (MalType, error) {
 		}
 	}
 	switch *tok {
+	case "'", "#'", "`", "~", "~@", "^", "@":
+		if tr.peek() == nil {
+			return nil, incompleteError("expected a form after " + *tok)
+		}
+	}
+	switch *tok {
 	case "'":
 		form, err := tr.ReadForm()
 		if err != nil {
 			return nil, err
 		}
 		return NewListOf(MalSymbol{Value: "quote"}, form), nil
+	case "#'":
+		form, err := tr.ReadForm()
+		if err != nil {
+			return nil, err
+		}
+		return NewListOf(MalSymbol{Value: "var"}, form), nil
 	case "`":
 		form, err := tr.ReadForm()
 		if err != nil {
@@ -158,6 +253,9 @@ func (tr *TokenReader) readAtom() (MalType, error) {
 		if err != nil {
 			return nil, err
 		}
+		if tr.peek() == nil {
+			return nil, incompleteError("expected a form after ^")
+		}
 		form, err := tr.ReadForm()
 		if err != nil {
 			return nil, err
@@ -192,7 +290,7 @@ func (tr *TokenReader) readList(start, end string) ([]MalType, error) {
 	for {
 		tok = tr.peek()
 		if tok == nil {
-			return nil, fmt.Errorf("expected %s", end)
+			return nil, incompleteError("expected " + end)
 		}
 		if *tok == end {
 			break
@@ -206,3 +304,27 @@ func (tr *TokenReader) readList(start, end string) ([]MalType, error) {
 	tr.next() // )
 	return list, nil
 }
+
+var charNames = map[string]rune{
+	"newline":   '\n',
+	"space":     ' ',
+	"tab":       '\t',
+	"return":    '\r',
+	"backspace": '\b',
+	"formfeed":  '\f',
+}
+
+func readChar(name string) (MalType, error) {
+	if ch, ok := charNames[name]; ok {
+		return MalChar{Value: ch}, nil
+	}
+	if runes := []rune(name); len(runes) == 1 {
+		return MalChar{Value: runes[0]}, nil
+	}
+	if len(name) == 5 && name[0] == 'u' {
+		if code, err := strconv.ParseUint(name[1:], 16, 32); err == nil && utf8.ValidRune(rune(code)) {
+			return MalChar{Value: rune(code)}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported character: \\%s", name)
+}
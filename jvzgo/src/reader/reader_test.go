@@ -0,0 +1,33 @@
+package reader
+
+import (
+	"testing"
+	. "types"
+)
+
+func TestReadChar(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want rune
+	}{
+		{`\a`, 'a'},
+		{`\newline`, '\n'},
+		{`\u00e9`, 'é'},
+		{`\uFFFD`, '\uFFFD'},
+		{`\é`, 'é'},
+	} {
+		got, err := ReadStr(tc.src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.src, err)
+			continue
+		}
+		if ch, ok := got.(MalChar); !ok || ch.Value != tc.want {
+			t.Errorf("%s read as %v, want %q", tc.src, got, tc.want)
+		}
+	}
+	for _, src := range []string{`\uD800`, `\udfff`, `\u12`, `\foo`} {
+		if got, err := ReadStr(src); err == nil {
+			t.Errorf("%s read as %v", src, got)
+		}
+	}
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
-	"bufio"
 	"core"
-	. "env"
 	"errors"
 	"fmt"
+	"io"
+	"lineedit"
+	"mal"
 	"os"
+	"path/filepath"
 	"printer"
 	"reader"
+	"sort"
 	"strings"
 	. "types"
 )
@@ -17,372 +20,251 @@ func READ(str string) (MalType, error) {
 	return reader.ReadStr(str)
 }
 
-func evalAst(ast MalType, env EnvType) (MalType, error) {
-	switch ast := ast.(type) {
-	case MalSymbol:
-		return env.Get(ast.Value)
-	case MalList:
-		evals := make([]MalType, len(ast.Value))
-		for i, arg := range ast.Value {
-			res, err := EVAL(arg, env)
-			if err != nil {
-				return nil, err
-			}
-			evals[i] = res
-		}
-		return ast.New(evals), nil
-	case MalMap:
-		evals := make(map[MalType]MalType)
-		for k, v := range ast.Value {
-			res, err := EVAL(v, env)
-			if err != nil {
-				return nil, err
-			}
-			evals[k] = res
-		}
-		return MalMap{Value: evals}, nil
-	default:
-		return ast, nil
-	}
+func PRINT(exp MalType) (string, error) {
+	return printer.PrintStr(exp, true), nil
 }
 
-func EVAL(ast MalType, env EnvType) (MalType, error) {
-	//fmt.Println(ast)
-	for {
-		if !IsList(ast) {
-			return evalAst(ast, env)
-		}
-		exp, err := macroexpand(ast, env)
-		if err != nil {
-			return nil, err
-		}
-		if !IsList(exp) {
-			return evalAst(exp, env)
-		}
-		list := exp.(MalList).Value
-		if len(list) == 0 {
-			return ast, nil
-		}
-		ast = exp
-		sym := "__<*fn*>__"
-		if s, ok := list[0].(MalSymbol); ok {
-			sym = s.Value
-		}
-		var a1 MalType
-		var a2 MalType
-		switch len(list) {
-		case 1:
-			a1 = nil
-			a2 = nil
-		case 2:
-			a1 = list[1]
-			a2 = nil
-		default:
-			a1 = list[1]
-			a2 = list[2]
-		}
-		switch sym {
-		case "def!":
-			// define a symbol in the given env
-			if len(list) != 3 {
-				return nil, fmt.Errorf("def! invalid args: %v", list)
-			}
-			key, err := GetSymbol(a1)
-			if err != nil {
-				return nil, err
-			}
-			val, err := EVAL(a2, env)
-			if err != nil {
-				return nil, err
-			}
-			env.Set(key.Value, val)
-			return val, nil
-
-		case "let*":
-			// create an inner env with ordered bindings and apply it to an expression
-			if len(list) != 3 {
-				return nil, fmt.Errorf("let* invalid args: %v", list)
-			}
-			binds, err := GetSlice(a1)
-			if err != nil {
-				return nil, err
-			}
-			if len(binds)&1 == 1 {
-				return nil, errors.New("odd number of binds provided to let*")
-			}
-			inner, err := env.New(nil, nil)
-			if err != nil {
-				return nil, err
-			}
-			for i := 0; i < len(binds); i += 2 {
-				sym, err := GetSymbol(binds[i])
-				if err != nil {
-					return nil, err
-				}
-				expr, err := EVAL(binds[i+1], inner)
-				if err != nil {
-					return nil, err
-				}
-				inner.Set(sym.Value, expr)
-			}
-			env = inner
-			ast = a2
-			continue
-
-		case "do":
-			// evaluate all arguments and return the last one's result
-			switch len(list) {
-			case 1:
-				return MalNil{}, nil
-			case 2:
-				ast = list[1]
-				continue
-			default:
-				if _, err := evalAst(NewList(list[1:len(list)-1]), env); err != nil {
-					return nil, err
-				}
-				ast = list[len(list)-1]
-				continue
-			}
+var interp *mal.Interpreter
 
-		case "if":
-			// check first arg, if not nil or false, evaluates and returns second arg
-			// otherwise, the third arg is evaluated and returned if provided or nil otherwise
-			if len(list) < 3 || len(list) > 4 {
-				return nil, fmt.Errorf("if invalid args: %v", list)
-			}
-			expr, err := EVAL(a1, env)
-			switch {
-			case err != nil:
-				return nil, err
-			case IsTruthy(expr):
-				ast = a2
-				continue
-			case len(list) < 4:
-				return MalNil{}, nil
-			default:
-				ast = list[3]
-				continue
-			}
+func rep(str string) (string, error) {
+	ast, err := READ(str)
+	if err != nil {
+		return "", err
+	}
+	exp, err := interp.Eval(ast)
+	if err != nil {
+		return "", err
+	}
+	return PRINT(exp)
+}
 
-		case "fn*":
-			// create a new function closure
-			if len(list) != 3 {
-				return nil, fmt.Errorf("fn* invalid args: %v", list)
-			}
-			binds, err := GetSlice(a1)
-			if err != nil {
-				return nil, err
-			}
-			return NewFunc(EVAL, binds, a2, env), nil
+// fail reports an error which ended a script, with the calls it propagated through, and exits with status 1.
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	for _, line := range Trace(err) {
+		fmt.Fprintln(os.Stderr, " ", line)
+	}
+	os.Exit(1)
+}
 
-		case "quote":
-			if len(list) != 2 {
-				return nil, fmt.Errorf("quote invalid args: %v", list)
-			}
-			return a1, nil
+const usage = `Usage: stepA_mal [options] [file | - | -m ns] [args...]
 
-		case "quasiquote":
-			if len(list) != 2 {
-				return nil, fmt.Errorf("quasiquote invalid args: %v", list)
-			}
-			ast = quasiquote(a1)
-			continue
+Runs a mal program from a file, from standard input given as -, or from the namespace ns, whose file is ns.mal
+with dots replaced by slashes and dashes by underscores and whose -main function is called with the args. With
+no program and no -e, starts the REPL. The args are bound to *ARGV*.
 
-		case "defmacro!":
-			// defines a macro symbol in the given env
-			if len(list) != 3 {
-				return nil, fmt.Errorf("defmacro! invalid args: %v", list)
-			}
-			key, err := GetSymbol(a1)
-			if err != nil {
-				return nil, err
-			}
-			val, err := EVAL(a2, env)
-			if err != nil {
-				return nil, err
-			}
-			fn, ok := val.(MalFunc)
-			if !ok {
-				return RaiseTypeError("function", val)
-			}
-			fn.SetMacro(true)
-			env.Set(key.Value, fn)
-			return fn, nil
+Options:
+  -e expr     evaluate expr and print its value unless it is nil; may be repeated
+  -i          start the REPL after running the program
+  -m ns       run the -main function of the namespace ns
+  --version   print the version and exit
+  -h, --help  print this help and exit
+  --          end the options; the remaining arguments are args
+`
 
-		case "macroexpand":
-			return macroexpand(a1, env)
+// nsPath returns the file holding the namespace ns.
+func nsPath(ns string) string {
+	return strings.NewReplacer(".", "/", "-", "_").Replace(ns) + ".mal"
+}
 
-		case "try*":
-			if len(list) != 3 {
-				return nil, fmt.Errorf("try* invalid args: %v", list)
-			}
-			catch, err := GetList(a2)
-			if err != nil {
-				return nil, err
-			}
-			if len(catch.Value) != 3 {
-				return nil, fmt.Errorf("catch* invalid args: %v", catch.Value)
-			}
-			sym, err := GetSymbol(catch.Value[0])
-			if err != nil {
-				return RaiseTypeError("symbol", catch.Value[0])
-			}
-			if sym.Value != "catch*" {
-				return RaiseTypeError("catch* symbol", sym)
-			}
-			try, err := EVAL(a1, env)
-			if err == nil {
-				return try, nil
-			}
-			var expr MalType
-			switch err := err.(type) {
-			case MalError:
-				expr = err.Value
-			default:
-				expr = MalString{Value: err.Error()}
-			}
-			inner, err := env.New(catch.Value[1:2], []MalType{expr})
-			if err != nil {
-				return nil, err
-			}
-			return EVAL(catch.Value[2], inner)
+// keywords holds the keywords seen in the REPL, which are offered as completions.
+var keywords = make(map[string]bool)
 
-		default:
-			// evaluate functions
-			eval, err := evalAst(ast, env)
-			if err != nil {
-				return nil, err
-			}
-			evals, err := GetSlice(eval)
-			if err != nil {
-				return nil, err
-			}
-			fn, err := GetFn(evals[0])
-			if err != nil {
-				return nil, err
-			}
-			return fn(evals[1:])
+// noteKeywords records the keywords in a form read or a value printed, without realizing lazy seqs.
+func noteKeywords(val MalType) {
+	switch val := val.(type) {
+	case MalKeyword:
+		keywords[val.Value] = true
+	case MalList:
+		for _, elem := range val.Value {
+			noteKeywords(elem)
+		}
+	case MalMap:
+		for key, elem := range val.Value {
+			noteKeywords(KeyValue(key))
+			noteKeywords(elem)
 		}
 	}
 }
 
-func isPair(val MalType) bool {
-	list, ok := val.(MalList)
-	return ok && len(list.Value) > 0
-}
-
-func quasiquote(ast MalType) MalType {
-	if !isPair(ast) {
-		return NewListOf(MalSymbol{Value: "quote"}, ast)
-	}
-	list, _ := GetSlice(ast)
-	if sym, ok := list[0].(MalSymbol); ok && sym.Value == "unquote" {
-		return list[1]
+// complete offers the symbols bound in the global environment and the special forms, or the keywords seen when
+// the word starts with a colon.
+func complete(word string) []string {
+	var names []string
+	if strings.HasPrefix(word, ":") {
+		for kw := range keywords {
+			names = append(names, ":"+kw)
+		}
+	} else {
+		names = append(interp.Symbols(), mal.SpecialForms...)
 	}
-	if isPair(list[0]) {
-		inner, _ := GetSlice(list[0])
-		if sym, ok := inner[0].(MalSymbol); ok && sym.Value == "splice-unquote" {
-			return NewListOf(MalSymbol{Value: "concat"}, inner[1], quasiquote(NewList(list[1:])))
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			matches = append(matches, name)
 		}
 	}
-	return NewListOf(MalSymbol{Value: "cons"}, quasiquote(list[0]), quasiquote(NewList(list[1:])))
+	sort.Strings(matches)
+	return matches
 }
 
-func isMacroCall(ast MalType, env EnvType) bool {
-	if !isPair(ast) {
-		return false
-	}
-	list := ast.(MalList).Value
-	if sym, ok := list[0].(MalSymbol); ok {
-		val, err := env.Get(sym.Value)
-		if err != nil {
-			return false
+// lineReader returns the function reading REPL input: a line editor keeping its history in ~/.mal-history when
+// the REPL runs in a terminal, and plain reads from standard input otherwise.
+func lineReader() func(prompt string) (string, bool, error) {
+	if !lineedit.Supported() {
+		return func(prompt string) (string, bool, error) {
+			fmt.Print(prompt)
+			return core.Stdin.ReadLine()
 		}
-		fn, ok := val.(MalFunc)
-		return ok && fn.IsMacro()
 	}
-	return false
-}
-
-func macroexpand(ast MalType, env EnvType) (MalType, error) {
-	for isMacroCall(ast, env) {
-		list := ast.(MalList).Value
-		sym := list[0].(MalSymbol)
-		val, _ := env.Get(sym.Value)
-		fn := val.(MalFunc)
-		res, err := fn.Fn()(list[1:])
-		if err != nil {
-			return nil, err
+	home, _ := os.UserHomeDir()
+	editor := lineedit.New(core.Stdin, filepath.Join(home, ".mal-history"))
+	editor.Complete = complete
+	return func(prompt string) (string, bool, error) {
+		line, err := editor.ReadLine(prompt)
+		if err == io.EOF {
+			return "", false, nil
 		}
-		ast = res
+		return line, err == nil, err
 	}
-	return ast, nil
 }
 
-func PRINT(exp MalType) (string, error) {
-	return printer.PrintStr(exp, true), nil
-}
-
-var replEnv = NewEnv()
-
-func rep(str string) (string, error) {
-	ast, err := READ(str)
-	if err != nil {
-		return "", err
+// repl reads lines until they hold only complete forms, showing a continuation prompt while a form is open, and
+// then evaluates each form in turn. Ctrl-C in the line editor abandons the input read so far. The last three
+// results are bound to *1, *2 and *3, and the last error to *e.
+func repl() {
+	rep(`(println (str "Mal [" *host-language* "]"))`)
+	for _, name := range []string{"*1", "*2", "*3", "*e"} {
+		interp.Define(name, MalNil{})
 	}
-	exp, err := EVAL(ast, replEnv)
-	if err != nil {
-		return "", err
+	readLine := lineReader()
+	var input string
+	prompt := "user> "
+	for {
+		line, ok, err := readLine(prompt)
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			input, prompt = "", "user> "
+			continue
+		}
+		if err != nil {
+			panic(err)
+		}
+		if !ok {
+			if input != "" {
+				_, err := reader.ReadSource(input, "REPL")
+				fmt.Println("Error:", err)
+			}
+			return
+		}
+		input += line + "\n"
+		forms, err := reader.ReadSource(input, "REPL")
+		if errors.Is(err, reader.ErrIncomplete) {
+			prompt = " ...> "
+			continue
+		}
+		input, prompt = "", "user> "
+		if err != nil {
+			fmt.Println("Error:", err)
+			continue
+		}
+		for _, form := range forms {
+			noteKeywords(form.Value)
+			exp, err := interp.EvalForm(form)
+			if err != nil {
+				interp.Define("*e", ErrorValue(err))
+				fmt.Println("Error:", err)
+				break
+			}
+			env := interp.Env()
+			star2, _ := env.Get("*2")
+			star1, _ := env.Get("*1")
+			interp.Define("*3", star2)
+			interp.Define("*2", star1)
+			interp.Define("*1", exp)
+			noteKeywords(exp)
+			result, _ := PRINT(exp)
+			fmt.Println(result)
+		}
 	}
-	return PRINT(exp)
 }
 
 func main() {
-	for sym, fn := range core.NS {
-		replEnv.Set(sym, fn)
-	}
-	replEnv.Set("eval", core.MonoErrFunc(func(a MalType) (MalType, error) {
-		return EVAL(a, replEnv)
-	}))
-	replEnv.Set("*host-language*", MalString{Value: "jvzgo"})
-	rep(`(def! not (fn* (a) (if a false true)))`)
-	rep(`(def! load-file (fn* (f) (eval (read-string (str "(do " (slurp f) ")")))))`)
-	rep(`(defmacro! cond (fn* (& xs) (if (> (count xs) 0) (list 'if (first xs) (if (> (count xs) 1) (nth xs 1) (throw "odd number of forms to cond")) (cons 'cond (rest (rest xs)))))))`)
-	rep("(def! *gensym-counter* (atom 0))")
-	rep("(def! gensym (fn* [] (symbol (str \"G__\" (swap! *gensym-counter* (fn* [x] (+ 1 x)))))))")
-	rep("(defmacro! or (fn* (& xs) (if (empty? xs) nil (if (= 1 (count xs)) (first xs) (let* (condvar (gensym)) `(let* (~condvar ~(first xs)) (if ~condvar ~condvar (or ~@(rest xs)))))))))")
-	if len(os.Args) > 1 {
-		filename := os.Args[1]
-		argv := make([]MalType, len(os.Args)-2)
-		if len(os.Args) > 2 {
-			for i, arg := range os.Args[2:] {
-				argv[i] = MalString{Value: arg}
+	var exprs []string
+	var file, ns string
+	interactive := false
+	args := os.Args[1:]
+options:
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		switch {
+		case arg == "-e" || arg == "-m":
+			if len(args) == 0 {
+				fmt.Fprintf(os.Stderr, "option %s needs an argument\n\n%s", arg, usage)
+				os.Exit(2)
+			}
+			if arg == "-e" {
+				exprs = append(exprs, args[0])
+				args = args[1:]
+				continue
 			}
+			ns = args[0]
+			args = args[1:]
+			break options
+		case arg == "-i":
+			interactive = true
+		case arg == "--version":
+			fmt.Println("jvzgo", mal.Version)
+			return
+		case arg == "-h" || arg == "--help":
+			fmt.Print(usage)
+			return
+		case arg == "--":
+			break options
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			file = arg
+			break options
+		default:
+			fmt.Fprintf(os.Stderr, "unknown option %s\n\n%s", arg, usage)
+			os.Exit(2)
 		}
-		replEnv.Set("*ARGV*", NewList(argv))
-		rep(`(load-file "` + filename + `")`)
-		return
 	}
-	replEnv.Set("*ARGV*", NewListOf())
-	rep(`(println (str "Mal [" *host-language* "]"))`)
-	in := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Print("user> ")
-		if in.Scan() {
-			read := strings.TrimSpace(in.Text())
-			result, err := rep(read)
-			if err != nil {
-				fmt.Println("Error:", err)
-			} else {
-				fmt.Println(result)
-			}
-		} else {
-			err := in.Err()
-			if err != nil {
-				panic(err)
+
+	interp = mal.NewInterpreter(mal.Options{Args: args})
+	for _, expr := range exprs {
+		res, err := interp.EvalString(expr)
+		if err != nil {
+			fail(err)
+		}
+		if !IsNil(res) {
+			fmt.Println(printer.PrintStr(res, true))
+		}
+	}
+	var err error
+	switch {
+	case file == "-":
+		var src string
+		if src, err = core.Stdin.ReadAll(); err == nil {
+			_, err = interp.EvalString(src)
+		}
+	case file != "":
+		_, err = interp.LoadFile(file)
+	case ns != "":
+		if _, err = interp.LoadFile(nsPath(ns)); err == nil {
+			var entry MalType
+			if entry, err = interp.Env().Get("-main"); err == nil {
+				argv := make([]MalType, len(args))
+				for i, arg := range args {
+					argv[i] = MalString{Value: arg}
+				}
+				_, err = interp.Call(entry, argv...)
 			}
-			return
 		}
 	}
+	if err != nil {
+		fail(err)
+	}
+	if interactive || (len(exprs) == 0 && file == "" && ns == "") {
+		repl()
+	}
 }
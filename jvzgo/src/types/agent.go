@@ -0,0 +1,235 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+var (
+	ErrAgentFailed    = errors.New("agent is failed, needs restart")
+	ErrAgentNotFailed = errors.New("agent does not need a restart")
+	ErrAwaitInAction  = errors.New("cannot await in an agent action")
+)
+
+// actionGoroutines holds the ids of the goroutines running an agent action, where waiting for agents could
+// deadlock.
+var actionGoroutines sync.Map
+
+// agentPool limits how many actions sent with send run at once. Actions sent with send-off may block and get a
+// goroutine each.
+var agentPool = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+type agentAction struct {
+	fn      func(MalType) (MalType, error)
+	offload bool
+}
+
+// MalAgent holds a value which is updated asynchronously by the actions sent to it. Actions run one at a time in
+// the order they were sent. An action which fails leaves its error on the agent, and the remaining actions wait
+// until the agent is restarted.
+type MalAgent struct {
+	lock      sync.Mutex
+	idle      *sync.Cond
+	state     MalType
+	err       error
+	queue     []agentAction
+	running   bool
+	sent      int
+	done      int
+	validator func([]MalType) (MalType, error)
+	meta      MalType
+}
+
+func NewAgent(val MalType) *MalAgent {
+	agent := &MalAgent{state: val}
+	agent.idle = sync.NewCond(&agent.lock)
+	return agent
+}
+
+func (ma *MalAgent) String() string {
+	return fmt.Sprint(ma.Value())
+}
+
+// Value returns the current state without waiting for pending actions.
+func (ma *MalAgent) Value() MalType {
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	return ma.state
+}
+
+// Failure returns the error of the action which failed the agent, if any.
+func (ma *MalAgent) Failure() error {
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	return ma.err
+}
+
+func (ma *MalAgent) Metadata() MalType {
+	return WrapNil(ma.meta)
+}
+
+func (ma *MalAgent) WithMeta(val MalType) *MalAgent {
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	agent := NewAgent(ma.state)
+	agent.validator = ma.validator
+	agent.meta = val
+	return agent
+}
+
+func (ma *MalAgent) Print(readably bool) string {
+	return "(agent " + Print(ma.Value(), readably) + ")"
+}
+
+func (ma *MalAgent) Equals(other MalType) bool {
+	return ma == other
+}
+
+func (ma *MalAgent) Hash() uint64 {
+	return hashPointer(ma)
+}
+
+func (ma *MalAgent) WithMetadata(meta MalType) (MalType, error) {
+	return ma.WithMeta(meta), nil
+}
+
+func (*MalAgent) TypeName() string {
+	return "agent"
+}
+
+// SetValidator installs a validator which every new state must satisfy, including the current one.
+func (ma *MalAgent) SetValidator(fn func([]MalType) (MalType, error)) error {
+	if fn != nil {
+		if err := validate(fn, ma.Value()); err != nil {
+			return err
+		}
+	}
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	ma.validator = fn
+	return nil
+}
+
+// Send queues fn to be called with the state of the agent, which is replaced by the result. Actions sent with
+// offload set may block and do not take a place in the shared pool.
+func (ma *MalAgent) Send(fn func(MalType) (MalType, error), offload bool) error {
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	if ma.err != nil {
+		return ErrAgentFailed
+	}
+	ma.queue = append(ma.queue, agentAction{fn: fn, offload: offload})
+	ma.sent++
+	if !ma.running {
+		ma.schedule()
+	}
+	return nil
+}
+
+// schedule starts the next queued action. The lock must be held.
+func (ma *MalAgent) schedule() {
+	action := ma.queue[0]
+	ma.queue = ma.queue[1:]
+	ma.running = true
+	if action.offload {
+		go ma.run(action)
+		return
+	}
+	go func() {
+		agentPool <- struct{}{}
+		defer func() { <-agentPool }()
+		ma.run(action)
+	}()
+}
+
+func (ma *MalAgent) run(action agentAction) {
+	val, err := ma.call(action)
+	ma.lock.Lock()
+	validator := ma.validator
+	ma.lock.Unlock()
+	if err == nil && validator != nil {
+		err = validate(validator, val)
+	}
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	if err != nil {
+		ma.err = err
+	} else {
+		ma.state = val
+	}
+	ma.done++
+	ma.running = false
+	if ma.err == nil && len(ma.queue) > 0 {
+		ma.schedule()
+	}
+	ma.idle.Broadcast()
+}
+
+// call runs the action on the state of the agent with the current goroutine marked as running an action.
+func (ma *MalAgent) call(action agentAction) (MalType, error) {
+	id := goroutineID()
+	actionGoroutines.Store(id, true)
+	defer actionGoroutines.Delete(id)
+	return action.fn(ma.Value())
+}
+
+// Await blocks until every action sent to the agent so far has run, or the agent fails. It fails when called from
+// an agent action, which could be waiting for itself.
+func (ma *MalAgent) Await() error {
+	if _, ok := actionGoroutines.Load(goroutineID()); ok {
+		return ErrAwaitInAction
+	}
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	target := ma.sent
+	for ma.done < target && ma.err == nil {
+		ma.idle.Wait()
+	}
+	if ma.err != nil {
+		return ErrAgentFailed
+	}
+	return nil
+}
+
+// Restart clears the error of a failed agent, sets its state and resumes the actions that were waiting, unless
+// clear is set in which case they are dropped.
+func (ma *MalAgent) Restart(val MalType, clear bool) error {
+	ma.lock.Lock()
+	validator := ma.validator
+	ma.lock.Unlock()
+	if validator != nil {
+		if err := validate(validator, val); err != nil {
+			return err
+		}
+	}
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	if ma.err == nil {
+		return ErrAgentNotFailed
+	}
+	ma.state = val
+	ma.err = nil
+	if clear {
+		ma.done += len(ma.queue)
+		ma.queue = nil
+	}
+	if len(ma.queue) > 0 {
+		ma.schedule()
+	}
+	ma.idle.Broadcast()
+	return nil
+}
+
+func GetAgent(val MalType) (*MalAgent, error) {
+	if agent, ok := val.(*MalAgent); ok {
+		return agent, nil
+	}
+	return nil, NewTypeError("agent", val)
+}
+
+func IsAgent(val MalType) bool {
+	_, ok := val.(*MalAgent)
+	return ok
+}
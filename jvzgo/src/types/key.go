@@ -0,0 +1,90 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// Hash-maps are Go maps, which compare their keys with ==. Keys Go cannot compare, such as vectors and maps, and
+// lazy seqs, which == compares by identity, are stored under a mapKey instead. Equal keys share one mapKey, found
+// by Hash and Equal in a table which holds mapKeys only while some map uses them.
+
+// mapKey stands for a key in the Go map of a hash-map.
+type mapKey struct {
+	val MalType
+}
+
+var (
+	keyLock sync.Mutex
+	// keyTable holds the mapKeys in use by the hash of their values.
+	keyTable = make(map[uint64][]weak.Pointer[mapKey])
+)
+
+// MapKey returns what a hash-map stores key under in its Go map, or an error for Go funcs, which are not equal
+// even to themselves. Metadata is dropped from values which Go can only compare without it, so that they find the
+// same entry as the value without metadata; KeyValue returns the key given for other keys.
+func MapKey(key MalType) (MalType, error) {
+	if key == nil || comparableKey(key) {
+		return key, nil
+	}
+	if val, ok := key.(MalValue); ok && !IsNil(val.Metadata()) {
+		if plain, err := val.WithMetadata(nil); err == nil && comparableKey(plain) {
+			return plain, nil
+		}
+	}
+	if _, ok := key.(func([]MalType) (MalType, error)); ok {
+		return nil, fmt.Errorf("cannot use %v as a hash-map key", TypeName(key))
+	}
+	return internKey(key), nil
+}
+
+// KeyValue returns the key a hash-map entry was stored with, given the key of its Go map.
+func KeyValue(key MalType) MalType {
+	if k, ok := key.(*mapKey); ok {
+		return k.val
+	}
+	return key
+}
+
+func comparableKey(key MalType) bool {
+	if _, ok := key.(*MalLazySeq); ok {
+		return false
+	}
+	return reflect.ValueOf(key).Comparable()
+}
+
+// internKey returns the mapKey of a value equal to key, making one for key if there is none.
+func internKey(key MalType) *mapKey {
+	h := Hash(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	for _, p := range keyTable[h] {
+		if k := p.Value(); k != nil && Equal(k.val, key) {
+			return k
+		}
+	}
+	k := &mapKey{val: key}
+	keyTable[h] = append(keyTable[h], weak.Make(k))
+	runtime.AddCleanup(k, dropKeys, h)
+	return k
+}
+
+// dropKeys removes the mapKeys which are no longer used from the table entry for h.
+func dropKeys(h uint64) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	live := keyTable[h][:0]
+	for _, p := range keyTable[h] {
+		if p.Value() != nil {
+			live = append(live, p)
+		}
+	}
+	if len(live) == 0 {
+		delete(keyTable, h)
+	} else {
+		keyTable[h] = live
+	}
+}
@@ -0,0 +1,174 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcessWaitDelay bounds how long output is still read once a process has exited, when processes it started
+// hold its pipes open.
+const ProcessWaitDelay = time.Second
+
+// MalProcess is a running subprocess with streams connected to its standard input, output and error. The streams
+// are plain pipes rather than the ones made by exec.Cmd, so that waiting for the process does not discard output
+// which has not been read yet. Waiting reads the output into memory while the process runs, so that a process
+// writing more than a pipe holds does not block, and closes the pipes.
+type MalProcess struct {
+	cmd    *exec.Cmd
+	ctx    context.Context
+	cancel context.CancelFunc
+	In     *MalStream
+	Out    *MalStream
+	Err    *MalStream
+	// outR and errR are the files read by Out and Err.
+	outR, errR *os.File
+	once       sync.Once
+	exit       int
+	err        error
+	// lock guards exited, which is set once Wait has reaped the process.
+	lock   sync.Mutex
+	exited bool
+}
+
+// StartProcess starts cmd, which must have been created with ctx. Cancelling ctx kills the process and cancel is
+// called once it has exited.
+func StartProcess(ctx context.Context, cancel context.CancelFunc, cmd *exec.Cmd) (*MalProcess, error) {
+	var pipes [6]*os.File
+	for i := 0; i < len(pipes); i += 2 {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeFiles(pipes[:i])
+			return nil, err
+		}
+		pipes[i], pipes[i+1] = r, w
+	}
+	inR, inW, outR, outW, errR, errW := pipes[0], pipes[1], pipes[2], pipes[3], pipes[4], pipes[5]
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = inR, outW, errW
+	if err := cmd.Start(); err != nil {
+		closeFiles(pipes[:])
+		return nil, err
+	}
+	// the child has its own copies of these ends
+	closeFiles([]*os.File{inR, outW, errW})
+	name := strings.Join(cmd.Args, " ")
+	return &MalProcess{
+		cmd:    cmd,
+		ctx:    ctx,
+		cancel: cancel,
+		In:     NewWriterStream(name+" stdin", inW, inW),
+		Out:    NewReaderStream(name+" stdout", outR, outR),
+		Err:    NewReaderStream(name+" stderr", errR, errR),
+		outR:   outR,
+		errR:   errR,
+	}, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func (p *MalProcess) String() string {
+	return fmt.Sprintf("#<process %d>", p.Pid())
+}
+
+func (p *MalProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// Wait waits for the process to exit and returns its exit code, which is -1 if it was killed by a signal. Waiting
+// again returns the same result. The output not yet read is read into memory while waiting, and kept to be read
+// from Out and Err once the pipes are closed; a goroutine reading Out or Err meanwhile waits for that.
+func (p *MalProcess) Wait() (int, error) {
+	p.once.Do(func() {
+		var drained sync.WaitGroup
+		for _, s := range []*MalStream{p.Out, p.Err} {
+			drained.Add(1)
+			go func(s *MalStream) {
+				defer drained.Done()
+				s.buffer()
+			}(s)
+		}
+		err := p.cmd.Wait()
+		p.lock.Lock()
+		p.exited = true
+		p.lock.Unlock()
+		p.cancel()
+		p.In.Close()
+		deadline := time.Now().Add(ProcessWaitDelay)
+		p.outR.SetReadDeadline(deadline)
+		p.errR.SetReadDeadline(deadline)
+		drained.Wait()
+		p.exit = p.cmd.ProcessState.ExitCode()
+		var exitErr *exec.ExitError
+		switch {
+		case errors.Is(p.ctx.Err(), context.DeadlineExceeded):
+			p.err = fmt.Errorf("%s timed out", p.cmd.Args[0])
+		case err != nil && !errors.As(err, &exitErr):
+			p.err = err
+		}
+	})
+	return p.exit, p.err
+}
+
+// Kill kills the process, along with the processes it started where the command kills its whole process group
+// when cancelled. Killing a process which has already exited does nothing; once Wait has reaped it, its pid is
+// not signalled at all, since it may belong to another process by then.
+func (p *MalProcess) Kill() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.exited {
+		return nil
+	}
+	kill := p.cmd.Process.Kill
+	if p.cmd.Cancel != nil {
+		kill = p.cmd.Cancel
+	}
+	if err := kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+func (p *MalProcess) Print(bool) string {
+	return p.String()
+}
+
+func (p *MalProcess) Equals(other MalType) bool {
+	return p == other
+}
+
+func (p *MalProcess) Hash() uint64 {
+	return hashPointer(p)
+}
+
+func (p *MalProcess) Metadata() MalType {
+	return MalNil{}
+}
+
+func (p *MalProcess) WithMetadata(MalType) (MalType, error) {
+	return noMeta(p)
+}
+
+func (*MalProcess) TypeName() string {
+	return "process"
+}
+
+func GetProcess(val MalType) (*MalProcess, error) {
+	if p, ok := val.(*MalProcess); ok {
+		return p, nil
+	}
+	return nil, NewTypeError("process", val)
+}
+
+func IsProcess(val MalType) bool {
+	_, ok := val.(*MalProcess)
+	return ok
+}
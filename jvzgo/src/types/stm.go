@@ -0,0 +1,407 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Refs are updated with software transactional memory. A transaction reads every ref as of the commit point
+// current when it started, keeps its writes private and publishes them all at once when it commits. Commits are
+// serialised and a transaction which finds that another one committed a ref it wrote or ensured since it started
+// is retried from the beginning. Old values are kept for a few commits so that a long transaction can still read
+// a consistent snapshot while newer transactions commit.
+//
+// A transaction has dynamic extent: everything called while it runs on a goroutine, including helper functions,
+// works in it. Go has no goroutine-local storage, so transactions are registered by goroutine id, which is only
+// looked up while some transaction is running. Go code also receives the transaction from RunTransaction.
+
+var (
+	// ErrRetry is returned while a transaction is aborted to be retried. It must be passed through unchanged.
+	ErrRetry         = errors.New("transaction retry")
+	ErrNoTransaction = errors.New("no transaction running")
+	ErrRetryLimit    = errors.New("transaction failed after reaching retry limit")
+	ErrCommuted      = errors.New("cannot set a ref after commute")
+)
+
+const (
+	retryLimit = 10000
+	maxHistory = 10
+)
+
+var (
+	// stmClock is the commit point of the last transaction committed.
+	stmClock   int64
+	commitLock sync.Mutex
+	// transactions maps goroutine ids to the transaction running on them, and running counts them.
+	transactions sync.Map
+	running      int32
+)
+
+type refVersion struct {
+	value MalType
+	point int64
+}
+
+// MalRef is a reference which may only be changed inside a transaction.
+type MalRef struct {
+	lock sync.RWMutex
+	// history holds the committed values, newest first.
+	history []refVersion
+	// faults counts reads which found no value old enough, and grows the history kept.
+	faults    int32
+	validator func([]MalType) (MalType, error)
+	meta      MalType
+}
+
+func NewRef(val MalType) *MalRef {
+	return &MalRef{history: []refVersion{{value: val}}}
+}
+
+func (ref *MalRef) String() string {
+	return fmt.Sprint(ref.Value())
+}
+
+// Value returns the last committed value.
+func (ref *MalRef) Value() MalType {
+	return ref.latest().value
+}
+
+func (ref *MalRef) latest() refVersion {
+	ref.lock.RLock()
+	defer ref.lock.RUnlock()
+	return ref.history[0]
+}
+
+// at returns the newest value committed at or before point.
+func (ref *MalRef) at(point int64) (MalType, bool) {
+	ref.lock.RLock()
+	defer ref.lock.RUnlock()
+	for _, version := range ref.history {
+		if version.point <= point {
+			return version.value, true
+		}
+	}
+	return nil, false
+}
+
+func (ref *MalRef) push(val MalType, point int64) {
+	ref.lock.Lock()
+	defer ref.lock.Unlock()
+	keep := int(atomic.LoadInt32(&ref.faults)) + 1
+	if keep > maxHistory {
+		keep = maxHistory
+	}
+	if len(ref.history) > keep-1 {
+		ref.history = ref.history[:keep-1]
+	}
+	ref.history = append([]refVersion{{value: val, point: point}}, ref.history...)
+}
+
+// Deref returns the value of the ref as seen by the transaction running on the current goroutine, or the last
+// committed value outside of a transaction.
+func (ref *MalRef) Deref() (MalType, error) {
+	if tx := currentTransaction(); tx != nil {
+		return tx.read(ref)
+	}
+	return ref.Value(), nil
+}
+
+func (ref *MalRef) Metadata() MalType {
+	return WrapNil(ref.meta)
+}
+
+func (ref *MalRef) WithMeta(val MalType) *MalRef {
+	r := NewRef(ref.Value())
+	r.validator = ref.validator
+	r.meta = val
+	return r
+}
+
+func (ref *MalRef) Print(readably bool) string {
+	return "(ref " + Print(ref.Value(), readably) + ")"
+}
+
+func (ref *MalRef) Equals(other MalType) bool {
+	return ref == other
+}
+
+func (ref *MalRef) Hash() uint64 {
+	return hashPointer(ref)
+}
+
+func (ref *MalRef) WithMetadata(meta MalType) (MalType, error) {
+	return ref.WithMeta(meta), nil
+}
+
+func (*MalRef) TypeName() string {
+	return "ref"
+}
+
+// SetValidator installs a validator which every committed value must satisfy, including the current one.
+func (ref *MalRef) SetValidator(fn func([]MalType) (MalType, error)) error {
+	if fn != nil {
+		if err := validate(fn, ref.Value()); err != nil {
+			return err
+		}
+	}
+	ref.lock.Lock()
+	defer ref.lock.Unlock()
+	ref.validator = fn
+	return nil
+}
+
+func validate(fn func([]MalType) (MalType, error), val MalType) error {
+	ok, err := fn([]MalType{val})
+	if err != nil {
+		return err
+	}
+	if !IsTruthy(ok) {
+		return ErrInvalidState
+	}
+	return nil
+}
+
+func GetRef(val MalType) (*MalRef, error) {
+	if ref, ok := val.(*MalRef); ok {
+		return ref, nil
+	}
+	return nil, NewTypeError("ref", val)
+}
+
+func IsRef(val MalType) bool {
+	_, ok := val.(*MalRef)
+	return ok
+}
+
+// Transaction holds the state of a dosync block while it runs on a goroutine.
+type Transaction struct {
+	readPoint int64
+	vals      map[*MalRef]MalType
+	sets      map[*MalRef]bool
+	ensures   map[*MalRef]bool
+	commutes  map[*MalRef][]func(MalType) (MalType, error)
+}
+
+// goroutineID parses the id of the current goroutine from its stack trace, which starts "goroutine 42 [".
+func goroutineID() int64 {
+	var buf [64]byte
+	stack := buf[:runtime.Stack(buf[:], false)]
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	id, _ := strconv.ParseInt(string(stack[:bytes.IndexByte(stack, ' ')]), 10, 64)
+	return id
+}
+
+func currentTransaction() *Transaction {
+	if atomic.LoadInt32(&running) == 0 {
+		return nil
+	}
+	if tx, ok := transactions.Load(goroutineID()); ok {
+		return tx.(*Transaction)
+	}
+	return nil
+}
+
+// CurrentTransaction returns the transaction running on the current goroutine.
+func CurrentTransaction() (*Transaction, error) {
+	if tx := currentTransaction(); tx != nil {
+		return tx, nil
+	}
+	return nil, ErrNoTransaction
+}
+
+// RunTransaction calls fn with a new transaction, retrying it until it commits. A transaction started while another
+// is running on the same goroutine joins the outer one. The transaction is current on the goroutine while fn runs,
+// but not while commute functions and validators run at its commit.
+func RunTransaction(fn func(*Transaction) (MalType, error)) (MalType, error) {
+	if tx := currentTransaction(); tx != nil {
+		return fn(tx)
+	}
+	id := goroutineID()
+	for i := 0; i < retryLimit; i++ {
+		tx := &Transaction{
+			readPoint: atomic.LoadInt64(&stmClock),
+			vals:      make(map[*MalRef]MalType),
+			sets:      make(map[*MalRef]bool),
+			ensures:   make(map[*MalRef]bool),
+			commutes:  make(map[*MalRef][]func(MalType) (MalType, error)),
+		}
+		val, err := runIn(id, tx, fn)
+		if err == nil {
+			err = tx.commit()
+		}
+		if !errors.Is(err, ErrRetry) {
+			return val, err
+		}
+		runtime.Gosched()
+	}
+	return nil, ErrRetryLimit
+}
+
+// runIn calls fn with tx registered as the transaction of the goroutine id.
+func runIn(id int64, tx *Transaction, fn func(*Transaction) (MalType, error)) (MalType, error) {
+	transactions.Store(id, tx)
+	atomic.AddInt32(&running, 1)
+	defer func() {
+		transactions.Delete(id)
+		atomic.AddInt32(&running, -1)
+	}()
+	return fn(tx)
+}
+
+// Deref returns the in-transaction value of the ref.
+func (tx *Transaction) Deref(ref *MalRef) (MalType, error) {
+	return tx.read(ref)
+}
+
+func (tx *Transaction) read(ref *MalRef) (MalType, error) {
+	if val, ok := tx.vals[ref]; ok {
+		return val, nil
+	}
+	if val, ok := ref.at(tx.readPoint); ok {
+		return val, nil
+	}
+	atomic.AddInt32(&ref.faults, 1)
+	return nil, ErrRetry
+}
+
+// changed reports whether a transaction committed the ref after this one started.
+func (tx *Transaction) changed(ref *MalRef) bool {
+	return ref.latest().point > tx.readPoint
+}
+
+// Set sets the in-transaction value of the ref.
+func (tx *Transaction) Set(ref *MalRef, val MalType) (MalType, error) {
+	if _, ok := tx.commutes[ref]; ok && !tx.sets[ref] {
+		return nil, ErrCommuted
+	}
+	if tx.changed(ref) {
+		return nil, ErrRetry
+	}
+	tx.vals[ref] = val
+	tx.sets[ref] = true
+	return val, nil
+}
+
+// Alter sets the ref to the result of calling f with its in-transaction value.
+func (tx *Transaction) Alter(ref *MalRef, f func(MalType) (MalType, error)) (MalType, error) {
+	old, err := tx.read(ref)
+	if err != nil {
+		return nil, err
+	}
+	val, err := f(old)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Set(ref, val)
+}
+
+// Commute sets the ref to the result of calling f with its in-transaction value, and calls f again with the
+// latest value when the transaction commits, so that commutative updates of the same ref do not conflict.
+func (tx *Transaction) Commute(ref *MalRef, f func(MalType) (MalType, error)) (MalType, error) {
+	old, err := tx.read(ref)
+	if err != nil {
+		return nil, err
+	}
+	val, err := f(old)
+	if err != nil {
+		return nil, err
+	}
+	tx.vals[ref] = val
+	tx.commutes[ref] = append(tx.commutes[ref], f)
+	return val, nil
+}
+
+// Ensure returns the in-transaction value of the ref and makes the transaction retry if another one changes the
+// ref before it commits.
+func (tx *Transaction) Ensure(ref *MalRef) (MalType, error) {
+	val, err := tx.read(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !tx.sets[ref] {
+		if tx.changed(ref) {
+			return nil, ErrRetry
+		}
+		tx.ensures[ref] = true
+	}
+	return val, nil
+}
+
+// commit publishes the writes of the transaction. Commute functions and validators are user code, so they run
+// before commitLock is taken, on the latest committed values of the commuted refs; if another transaction commits
+// one of those refs meanwhile, they are run again.
+func (tx *Transaction) commit() error {
+	if len(tx.sets) == 0 && len(tx.commutes) == 0 {
+		return nil
+	}
+	for {
+		vals := make(map[*MalRef]MalType, len(tx.vals))
+		for ref, val := range tx.vals {
+			vals[ref] = val
+		}
+		// seen holds the commit point of the value each commute started from
+		seen := make(map[*MalRef]int64, len(tx.commutes))
+		for ref, fns := range tx.commutes {
+			if tx.sets[ref] {
+				continue
+			}
+			latest := ref.latest()
+			val := latest.value
+			for _, f := range fns {
+				var err error
+				if val, err = f(val); err != nil {
+					return err
+				}
+			}
+			vals[ref] = val
+			seen[ref] = latest.point
+		}
+		for ref, val := range vals {
+			ref.lock.RLock()
+			validator := ref.validator
+			ref.lock.RUnlock()
+			if validator == nil {
+				continue
+			}
+			if err := validate(validator, val); err != nil {
+				return err
+			}
+		}
+		if done, err := tx.publish(vals, seen); done || err != nil {
+			return err
+		}
+	}
+}
+
+// publish commits vals unless a ref the transaction set or ensured has changed since it started, which makes it
+// retry, or a commuted ref has changed since its commute functions ran, which makes publish report false.
+func (tx *Transaction) publish(vals map[*MalRef]MalType, seen map[*MalRef]int64) (bool, error) {
+	commitLock.Lock()
+	defer commitLock.Unlock()
+	for ref := range tx.sets {
+		if tx.changed(ref) {
+			return false, ErrRetry
+		}
+	}
+	for ref := range tx.ensures {
+		if tx.changed(ref) {
+			return false, ErrRetry
+		}
+	}
+	for ref, point := range seen {
+		if ref.latest().point != point {
+			return false, nil
+		}
+	}
+	point := atomic.LoadInt64(&stmClock) + 1
+	for ref, val := range vals {
+		ref.push(val, point)
+	}
+	atomic.StoreInt64(&stmClock, point)
+	return true, nil
+}
@@ -0,0 +1,176 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrStreamClosed      = errors.New("stream is closed")
+	ErrStreamNotReadable = errors.New("stream is not readable")
+	ErrStreamNotWritable = errors.New("stream is not writable")
+)
+
+// MalStream is an open file, pipe or standard stream which can be read from or written to. Reads are buffered, so
+// every read of the same input has to go through the same stream to avoid losing data.
+type MalStream struct {
+	lock   sync.Mutex
+	name   string
+	reader *bufio.Reader
+	writer io.Writer
+	closer io.Closer
+	closed bool
+}
+
+// NewReaderStream creates a stream reading from r. Closing the stream closes closer unless it is nil.
+func NewReaderStream(name string, r io.Reader, closer io.Closer) *MalStream {
+	return &MalStream{name: name, reader: bufio.NewReader(r), closer: closer}
+}
+
+// NewWriterStream creates a stream writing to w. Closing the stream closes closer unless it is nil.
+func NewWriterStream(name string, w io.Writer, closer io.Closer) *MalStream {
+	return &MalStream{name: name, writer: w, closer: closer}
+}
+
+func (s *MalStream) String() string {
+	return "#<stream " + s.name + ">"
+}
+
+func (s *MalStream) Name() string {
+	return s.name
+}
+
+func (s *MalStream) readable() error {
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if s.reader == nil {
+		return ErrStreamNotReadable
+	}
+	return nil
+}
+
+// ReadLine reads the next line without its line ending. It returns false at the end of the input.
+func (s *MalStream) ReadLine() (string, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.readable(); err != nil {
+		return "", false, err
+	}
+	line, err := s.reader.ReadString('\n')
+	if err == io.EOF {
+		if line == "" {
+			return "", false, nil
+		}
+		err = nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), true, nil
+}
+
+// ReadRune reads the next character, so that code reading input a key at a time can share the stream's buffer.
+func (s *MalStream) ReadRune() (rune, int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.readable(); err != nil {
+		return 0, 0, err
+	}
+	return s.reader.ReadRune()
+}
+
+// ReadAll reads the rest of the input.
+func (s *MalStream) ReadAll() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.readable(); err != nil {
+		return "", err
+	}
+	var str strings.Builder
+	_, err := io.Copy(&str, s.reader)
+	return str.String(), err
+}
+
+func (s *MalStream) Write(str string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if s.writer == nil {
+		return ErrStreamNotWritable
+	}
+	_, err := io.WriteString(s.writer, str)
+	return err
+}
+
+// Close closes the underlying file. Closing a stream again does nothing.
+func (s *MalStream) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// buffer reads the rest of the input into memory and closes the underlying file, so that what is left can still
+// be read without keeping the file open. Reading stops early at the file's read deadline, if it has one. Reads
+// from other goroutines wait until it is done. A stream which is closed is left as it is.
+func (s *MalStream) buffer() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed || s.reader == nil || s.closer == nil {
+		return
+	}
+	rest, _ := io.ReadAll(s.reader)
+	s.reader = bufio.NewReader(bytes.NewReader(rest))
+	s.closer.Close()
+	s.closer = nil
+}
+
+func (s *MalStream) Print(bool) string {
+	return s.String()
+}
+
+func (s *MalStream) Equals(other MalType) bool {
+	return s == other
+}
+
+func (s *MalStream) Hash() uint64 {
+	return hashPointer(s)
+}
+
+func (s *MalStream) Metadata() MalType {
+	return MalNil{}
+}
+
+func (s *MalStream) WithMetadata(MalType) (MalType, error) {
+	return noMeta(s)
+}
+
+func (*MalStream) TypeName() string {
+	return "stream"
+}
+
+func GetStream(val MalType) (*MalStream, error) {
+	if s, ok := val.(*MalStream); ok {
+		return s, nil
+	}
+	return nil, NewTypeError("stream", val)
+}
+
+func IsStream(val MalType) bool {
+	_, ok := val.(*MalStream)
+	return ok
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	maxFrames     = 32
+	maxFrameWidth = 100
+)
+
+// TracedError is an error annotated with the calls it propagated through, innermost first. Its message is that of
+// the underlying error, so catching it gives the same value as catching the error itself. A TracedError is never
+// modified, since the same error may be rethrown from several places, such as every deref of a failed future.
+type TracedError struct {
+	Err    error
+	frames []MalType
+	// omitted counts the outer calls left out once the trace is full.
+	omitted int
+}
+
+func (e *TracedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TracedError) Unwrap() error {
+	return e.Err
+}
+
+// AddFrame records that err propagated out of evaluating the call form.
+func AddFrame(err error, form MalType) error {
+	e, ok := err.(*TracedError)
+	if !ok {
+		return &TracedError{Err: err, frames: []MalType{form}}
+	}
+	if len(e.frames) >= maxFrames {
+		return &TracedError{Err: e.Err, frames: e.frames, omitted: e.omitted + 1}
+	}
+	return &TracedError{Err: e.Err, frames: append(e.frames[:len(e.frames):len(e.frames)], form), omitted: e.omitted}
+}
+
+// Trace describes the calls an error propagated through, one line for each, innermost first.
+func Trace(err error) []string {
+	var e *TracedError
+	if !errors.As(err, &e) {
+		return nil
+	}
+	trace := make([]string, 0, len(e.frames)+1)
+	for _, form := range e.frames {
+		str := Print(form, true)
+		if len(str) > maxFrameWidth {
+			str = str[:maxFrameWidth-3] + "..."
+		}
+		trace = append(trace, "at "+str)
+	}
+	if e.omitted > 0 {
+		trace = append(trace, fmt.Sprintf("... %d more", e.omitted))
+	}
+	return trace
+}
+
+// ErrorValue converts an error into the value a catch* block sees: the value thrown by throw, or the message of
+// any other error.
+func ErrorValue(err error) MalType {
+	var e MalError
+	if errors.As(err, &e) {
+		return e.Value
+	}
+	return MalString{Value: err.Error()}
+}
@@ -1,9 +1,16 @@
 package types
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type MalType interface {
@@ -14,6 +21,10 @@ type EnvType interface {
 	Find(key string) EnvType
 	Get(key string) (MalType, error)
 	New(binds, exprs []MalType) (EnvType, error)
+	// SetMeta sets the metadata of the binding of key in this environment, such as the docstring given to def!.
+	SetMeta(key string, meta MalType)
+	// Meta returns the metadata of the binding of key, or nil.
+	Meta(key string) MalType
 }
 
 func NewTypeError(expectedType string, actual MalType) error {
@@ -105,11 +116,155 @@ func GetVec(val MalType) (MalList, error) {
 }
 
 func GetSlice(val MalType) ([]MalType, error) {
-	list, ok := val.(MalList)
-	if !ok {
+	switch list := val.(type) {
+	case MalList:
+		return list.Value, nil
+	case *MalLazySeq:
+		return list.ToSlice()
+	default:
 		return nil, fmt.Errorf("provided value is not sliceable: %v", val)
 	}
-	return list.Value, nil
+}
+
+// ErrRecursiveSeq is returned when realizing a lazy seq needs the value of the same seq.
+var ErrRecursiveSeq = errors.New("lazy seq needs its own value to be realized")
+
+// MalLazySeq is a sequence whose contents are computed on demand by a thunk. Once realized, a lazy seq is
+// either empty or a cons cell of a first element and the rest of the sequence, which may itself be lazy.
+type MalLazySeq struct {
+	lock  sync.Mutex
+	thunk func() (MalType, error)
+	// running counts the calls of thunk in progress.
+	running  int
+	realized bool
+	empty    bool
+	first    MalType
+	rest     MalType
+	err      error
+	meta     MalType
+}
+
+func NewLazySeq(thunk func() (MalType, error)) *MalLazySeq {
+	return &MalLazySeq{thunk: thunk}
+}
+
+// NewLazyCons creates an already realized lazy seq with the given first element and rest sequence.
+func NewLazyCons(first, rest MalType) *MalLazySeq {
+	if IsNil(rest) {
+		rest = NewListOf()
+	}
+	return &MalLazySeq{realized: true, first: first, rest: rest}
+}
+
+// realize calls the thunk without holding the lock, since it may use the seq itself, and keeps the result of the
+// first call to finish when several goroutines realize the seq at once. A goroutine can only be running the thunk
+// twice if the thunk needs the seq it realizes, so once more calls are in progress than there are goroutines,
+// realize reports ErrRecursiveSeq instead of recursing forever.
+func (ls *MalLazySeq) realize() error {
+	ls.lock.Lock()
+	if ls.realized {
+		ls.lock.Unlock()
+		return ls.err
+	}
+	if ls.running >= runtime.NumGoroutine() {
+		ls.lock.Unlock()
+		return ErrRecursiveSeq
+	}
+	ls.running++
+	thunk := ls.thunk
+	ls.lock.Unlock()
+
+	var first, rest MalType
+	ok := false
+	val, err := thunk()
+	if err == nil {
+		first, rest, ok, err = SeqNext(WrapNil(val))
+	}
+
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.running--
+	if ls.realized {
+		return ls.err
+	}
+	ls.thunk = nil
+	ls.realized = true
+	switch {
+	case err != nil:
+		ls.err = err
+	case !ok:
+		ls.empty = true
+	default:
+		ls.first = first
+		ls.rest = rest
+	}
+	return ls.err
+}
+
+// ToSlice realizes the entire sequence. This never returns for infinite sequences.
+func (ls *MalLazySeq) ToSlice() ([]MalType, error) {
+	vals := make([]MalType, 0)
+	var seq MalType = ls
+	for {
+		first, rest, ok, err := SeqNext(seq)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return vals, nil
+		}
+		vals = append(vals, first)
+		seq = rest
+	}
+}
+
+func (ls *MalLazySeq) String() string {
+	vals, err := ls.ToSlice()
+	if err != nil {
+		return "#<lazy-seq>"
+	}
+	return NewList(vals).String()
+}
+
+func (ls *MalLazySeq) Metadata() MalType {
+	return WrapNil(ls.meta)
+}
+
+func (ls *MalLazySeq) WithMeta(val MalType) *MalLazySeq {
+	seq := NewLazySeq(func() (MalType, error) {
+		return ls, nil
+	})
+	seq.meta = val
+	return seq
+}
+
+func IsLazySeq(val MalType) bool {
+	_, ok := val.(*MalLazySeq)
+	return ok
+}
+
+// SeqNext splits a sequence into its first element and the rest of the sequence, realizing only as much of a
+// lazy seq as needed. ok is false when the sequence is empty.
+func SeqNext(val MalType) (first MalType, rest MalType, ok bool, err error) {
+	switch seq := val.(type) {
+	case MalNil:
+		return nil, nil, false, nil
+	case MalList:
+		if len(seq.Value) == 0 {
+			return nil, nil, false, nil
+		}
+		return seq.Value[0], NewList(seq.Value[1:]), true, nil
+	case *MalLazySeq:
+		if err := seq.realize(); err != nil {
+			return nil, nil, false, err
+		}
+		if seq.empty {
+			return nil, nil, false, nil
+		}
+		return seq.first, seq.rest, true, nil
+	default:
+		return nil, nil, false, NewTypeError("sequence", val)
+	}
 }
 
 type MalMap struct {
@@ -137,33 +292,172 @@ func CopyMap(val MalMap) MalMap {
 	return MalMap{Value: m}
 }
 
+// MalAtom is a reference to a value which may be updated atomically from several goroutines. Updates are
+// checked by an optional validator and reported to watches after they are made.
 type MalAtom struct {
+	value     atomic.Value // *atomBox
+	lock      sync.Mutex
+	validator func([]MalType) (MalType, error)
+	watches   map[MalType]func([]MalType) (MalType, error)
+	meta      MalType
+}
+
+// atomBox gives each value stored in an atom a distinct identity for compare-and-swap.
+type atomBox struct {
 	value MalType
-	meta  MalType
 }
 
+var ErrInvalidState = errors.New("invalid reference state")
+
 func (ma *MalAtom) String() string {
-	return fmt.Sprint(ma.value)
+	return fmt.Sprint(ma.Value())
+}
+
+func (ma *MalAtom) load() *atomBox {
+	return ma.value.Load().(*atomBox)
 }
 
 func (ma *MalAtom) Value() MalType {
-	return ma.value
+	return ma.load().value
 }
 
+// SetValue stores a value without validating it or notifying watches.
 func (ma *MalAtom) SetValue(val MalType) {
-	ma.value = val
+	ma.value.Store(&atomBox{value: val})
 }
 
-func (ma *MalAtom) Meta() MalType {
+func (ma *MalAtom) Metadata() MalType {
 	return WrapNil(ma.meta)
 }
 
 func (ma *MalAtom) WithMeta(val MalType) *MalAtom {
-	return &MalAtom{value: ma.value, meta: val}
+	atom := NewAtom(ma.Value())
+	atom.meta = val
+	return atom
+}
+
+func (ma *MalAtom) validate(val MalType) error {
+	ma.lock.Lock()
+	validator := ma.validator
+	ma.lock.Unlock()
+	if validator == nil {
+		return nil
+	}
+	ok, err := validator([]MalType{val})
+	if err != nil {
+		return err
+	}
+	if !IsTruthy(ok) {
+		return ErrInvalidState
+	}
+	return nil
+}
+
+func (ma *MalAtom) notify(old, new MalType) error {
+	ma.lock.Lock()
+	watches := make(map[MalType]func([]MalType) (MalType, error), len(ma.watches))
+	for key, fn := range ma.watches {
+		watches[key] = fn
+	}
+	ma.lock.Unlock()
+	for key, fn := range watches {
+		if _, err := fn([]MalType{KeyValue(key), ma, old, new}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Swap atomically replaces the value with the result of f, calling f again with the latest value whenever
+// another goroutine updated the atom in the meantime. It returns the replaced and the new value.
+func (ma *MalAtom) Swap(f func(MalType) (MalType, error)) (MalType, MalType, error) {
+	for {
+		old := ma.load()
+		val, err := f(old.value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := ma.validate(val); err != nil {
+			return nil, nil, err
+		}
+		if ma.value.CompareAndSwap(old, &atomBox{value: val}) {
+			return old.value, val, ma.notify(old.value, val)
+		}
+	}
+}
+
+// Reset unconditionally replaces the value, returning the replaced value.
+func (ma *MalAtom) Reset(val MalType) (MalType, error) {
+	old, _, err := ma.Swap(func(MalType) (MalType, error) {
+		return val, nil
+	})
+	return old, err
+}
+
+// CompareAndSet replaces the value only if the current value is equal to old according to eq.
+func (ma *MalAtom) CompareAndSet(old, val MalType, eq func(MalType, MalType) bool) (bool, error) {
+	for {
+		current := ma.load()
+		if !eq(current.value, old) {
+			return false, nil
+		}
+		if err := ma.validate(val); err != nil {
+			return false, err
+		}
+		if ma.value.CompareAndSwap(current, &atomBox{value: val}) {
+			return true, ma.notify(current.value, val)
+		}
+	}
+}
+
+// SetValidator installs a validator, or removes it if fn is nil. The current value must satisfy the validator.
+func (ma *MalAtom) SetValidator(fn func([]MalType) (MalType, error)) error {
+	if fn != nil {
+		ok, err := fn([]MalType{ma.Value()})
+		if err != nil {
+			return err
+		}
+		if !IsTruthy(ok) {
+			return ErrInvalidState
+		}
+	}
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	ma.validator = fn
+	return nil
+}
+
+// AddWatch registers fn to be called with the key, the atom and the old and new values after each change. Keys
+// are compared as hash-map keys are.
+func (ma *MalAtom) AddWatch(key MalType, fn func([]MalType) (MalType, error)) error {
+	key, err := MapKey(key)
+	if err != nil {
+		return err
+	}
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	if ma.watches == nil {
+		ma.watches = make(map[MalType]func([]MalType) (MalType, error))
+	}
+	ma.watches[key] = fn
+	return nil
+}
+
+func (ma *MalAtom) RemoveWatch(key MalType) error {
+	key, err := MapKey(key)
+	if err != nil {
+		return err
+	}
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+	delete(ma.watches, key)
+	return nil
 }
 
 func NewAtom(val MalType) *MalAtom {
-	return &MalAtom{value: val}
+	atom := &MalAtom{}
+	atom.SetValue(val)
+	return atom
 }
 
 func GetAtom(val MalType) (*MalAtom, error) {
@@ -178,6 +472,254 @@ func IsAtom(val MalType) bool {
 	return ok
 }
 
+// MalFuture is a value which is delivered once, possibly by another goroutine. Futures are delivered by the
+// computation they run and promises explicitly with Deliver.
+type MalFuture struct {
+	done      chan struct{}
+	once      sync.Once
+	value     MalType
+	err       error
+	cancelled bool
+	promise   bool
+}
+
+var ErrCancelled = errors.New("future cancelled")
+
+// NewFuture runs fn on a new goroutine and delivers its result to the returned future.
+func NewFuture(fn func() (MalType, error)) *MalFuture {
+	mf := &MalFuture{done: make(chan struct{})}
+	go func() {
+		val, err := fn()
+		mf.deliver(val, err, false)
+	}()
+	return mf
+}
+
+func NewPromise() *MalFuture {
+	return &MalFuture{done: make(chan struct{}), promise: true}
+}
+
+func (mf *MalFuture) deliver(val MalType, err error, cancelled bool) bool {
+	delivered := false
+	mf.once.Do(func() {
+		mf.value, mf.err, mf.cancelled = val, err, cancelled
+		close(mf.done)
+		delivered = true
+	})
+	return delivered
+}
+
+// Deliver sets the value of a promise. It returns false if a value has already been delivered.
+func (mf *MalFuture) Deliver(val MalType) bool {
+	return mf.deliver(val, nil, false)
+}
+
+// Cancel abandons a pending future so that waiting on it fails with ErrCancelled. The goroutine computing the
+// future is not interrupted but its result is discarded.
+func (mf *MalFuture) Cancel() bool {
+	return mf.deliver(nil, ErrCancelled, true)
+}
+
+func (mf *MalFuture) IsDone() bool {
+	select {
+	case <-mf.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (mf *MalFuture) IsCancelled() bool {
+	return mf.IsDone() && mf.cancelled
+}
+
+func (mf *MalFuture) IsPromise() bool {
+	return mf.promise
+}
+
+// Wait blocks until the future has been delivered and returns its value.
+func (mf *MalFuture) Wait() (MalType, error) {
+	<-mf.done
+	return mf.value, mf.err
+}
+
+// WaitTimeout is like Wait but gives up after the timeout, returning ok as false.
+func (mf *MalFuture) WaitTimeout(timeout time.Duration) (val MalType, ok bool, err error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-mf.done:
+		return mf.value, true, mf.err
+	case <-timer.C:
+		return nil, false, nil
+	}
+}
+
+func GetFuture(val MalType) (*MalFuture, error) {
+	if mf, ok := val.(*MalFuture); ok {
+		return mf, nil
+	}
+	return nil, NewTypeError("future", val)
+}
+
+func IsFuture(val MalType) bool {
+	mf, ok := val.(*MalFuture)
+	return ok && !mf.promise
+}
+
+func IsPromise(val MalType) bool {
+	mf, ok := val.(*MalFuture)
+	return ok && mf.promise
+}
+
+// MalChan is a channel for communicating between goroutines. Taking from a closed channel yields nil, so nil
+// cannot be put on a channel.
+type MalChan struct {
+	*chanState
+	meta MalType
+}
+
+// chanState holds the Go channel, which is never closed so that a put can never panic. Closing a MalChan closes
+// done instead, which wakes blocked puts and takes; takes still receive the values left in ch.
+type chanState struct {
+	ch   chan MalType
+	done chan struct{}
+	once sync.Once
+}
+
+func NewChan(size int) *MalChan {
+	return &MalChan{chanState: &chanState{ch: make(chan MalType, size), done: make(chan struct{})}}
+}
+
+// Put sends a value, blocking until it is accepted. It returns false if the channel is closed.
+func (mc *MalChan) Put(val MalType) bool {
+	if mc.IsClosed() {
+		return false
+	}
+	select {
+	case mc.ch <- val:
+		return true
+	case <-mc.done:
+		return false
+	}
+}
+
+// Take receives a value, blocking until one is available. It returns nil once the channel is closed and drained.
+func (mc *MalChan) Take() MalType {
+	select {
+	case val := <-mc.ch:
+		return val
+	case <-mc.done:
+		return mc.drain()
+	}
+}
+
+// drain takes a value left in a closed channel, or returns nil.
+func (mc *MalChan) drain() MalType {
+	select {
+	case val := <-mc.ch:
+		return val
+	default:
+		return MalNil{}
+	}
+}
+
+func (mc *MalChan) Close() {
+	mc.once.Do(func() {
+		close(mc.done)
+	})
+}
+
+func (mc *MalChan) IsClosed() bool {
+	select {
+	case <-mc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsSame reports whether both values refer to the same channel.
+func (mc *MalChan) IsSame(other *MalChan) bool {
+	return mc.chanState == other.chanState
+}
+
+func (mc *MalChan) Len() int {
+	return len(mc.ch)
+}
+
+func (mc *MalChan) Cap() int {
+	return cap(mc.ch)
+}
+
+func (mc *MalChan) Metadata() MalType {
+	return WrapNil(mc.meta)
+}
+
+func (mc *MalChan) WithMeta(val MalType) *MalChan {
+	// channels are identities, so the copy shares the underlying channel
+	return &MalChan{chanState: mc.chanState, meta: val}
+}
+
+// ChanOp is a take from a channel, or a put of Val when it is not nil, for use with Select.
+type ChanOp struct {
+	Chan *MalChan
+	Val  MalType
+}
+
+// ErrNoChannels is returned by a blocking Select without operations, which would wait forever.
+var ErrNoChannels = errors.New("no channels to wait on")
+
+// Select performs whichever of the operations can proceed first, choosing randomly among those ready at once.
+// It returns the index of the operation and the value taken, or whether the value was put. When block is false
+// and no operation is ready, the index is -1.
+func Select(ops []ChanOp, block bool) (int, MalType, error) {
+	if len(ops) == 0 && block {
+		return 0, nil, ErrNoChannels
+	}
+	// each operation has a case for its channel followed by one for the channel being closed
+	cases := make([]reflect.SelectCase, 0, 2*len(ops)+1)
+	for i, op := range ops {
+		if op.Val == nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(op.Chan.ch)})
+		} else if op.Chan.IsClosed() {
+			return i, MalFalse, nil
+		} else {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(op.Chan.ch), Send: reflect.ValueOf(&op.Val).Elem()})
+		}
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(op.Chan.done)})
+	}
+	if !block {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+	chosen, recv, _ := reflect.Select(cases)
+	i := chosen / 2
+	switch {
+	case i == len(ops):
+		return -1, nil, nil
+	case chosen%2 == 1 && ops[i].Val != nil:
+		return i, MalFalse, nil
+	case chosen%2 == 1:
+		return i, ops[i].Chan.drain(), nil
+	case ops[i].Val != nil:
+		return i, MalTrue, nil
+	default:
+		return i, recv.Interface().(MalType), nil
+	}
+}
+
+func GetChan(val MalType) (*MalChan, error) {
+	if mc, ok := val.(*MalChan); ok {
+		return mc, nil
+	}
+	return nil, NewTypeError("chan", val)
+}
+
+func IsChan(val MalType) bool {
+	_, ok := val.(*MalChan)
+	return ok
+}
+
 type MalSymbol struct {
 	Value string
 	Meta  MalType
@@ -220,6 +762,94 @@ func IsString(val MalType) bool {
 	return ok
 }
 
+type MalRegex struct {
+	Value *regexp.Regexp
+	// whole is Value anchored to match only whole strings, compiled once for re-matches.
+	whole *regexp.Regexp
+	Meta  MalType
+}
+
+func NewRegex(pattern string) (MalRegex, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return MalRegex{}, err
+	}
+	return MalRegex{Value: re, whole: regexp.MustCompile(anchored(re))}, nil
+}
+
+func anchored(re *regexp.Regexp) string {
+	return `^(?:` + re.String() + `)$`
+}
+
+// Whole returns the regex anchored so that it only matches a whole string.
+func (mr MalRegex) Whole() *regexp.Regexp {
+	if mr.whole == nil {
+		// a MalRegex made without NewRegex
+		return regexp.MustCompile(anchored(mr.Value))
+	}
+	return mr.whole
+}
+
+func (mr MalRegex) String() string {
+	return mr.Value.String()
+}
+
+func GetRegex(val MalType) (MalRegex, error) {
+	if mr, ok := val.(MalRegex); ok {
+		return mr, nil
+	}
+	return MalRegex{}, NewTypeError("regex", val)
+}
+
+func IsRegex(val MalType) bool {
+	_, ok := val.(MalRegex)
+	return ok
+}
+
+type MalChar struct {
+	Value rune
+	Meta  MalType
+}
+
+func (mc MalChar) String() string {
+	return string(mc.Value)
+}
+
+func GetChar(val MalType) (MalChar, error) {
+	if mc, ok := val.(MalChar); ok {
+		return mc, nil
+	}
+	return MalChar{}, NewTypeError("char", val)
+}
+
+func IsChar(val MalType) bool {
+	_, ok := val.(MalChar)
+	return ok
+}
+
+// MalGo wraps a Go value which has no mal equivalent, such as a struct, a pointer or a float, so that it can be
+// passed back to Go code and have its methods called.
+type MalGo struct {
+	Value interface{}
+	Meta  MalType
+}
+
+func (mg MalGo) String() string {
+	return fmt.Sprint(mg.Value)
+}
+
+func GetGo(val MalType) (MalGo, error) {
+	if mg, ok := val.(MalGo); ok {
+		return mg, nil
+	}
+	return MalGo{}, NewTypeError("go value", val)
+}
+
+func IsGo(val MalType) bool {
+	_, ok := val.(MalGo)
+	return ok
+}
+
 type MalKeyword struct {
 	Value string
 	Meta  MalType
@@ -329,10 +959,20 @@ func IsTruthy(val MalType) bool {
 }
 
 type MalFn struct {
-	fn   func([]MalType) (MalType, error)
+	fn func([]MalType) (MalType, error)
+	// id identifies the builtin, since funcs cannot be compared and builtins made by the same wrapper, such as
+	// BiFunc, share their code. Copies made by WithMetadata keep it.
+	id   *fnID
 	meta MalType
 }
 
+type fnID struct{ _ byte }
+
+// NewFn returns a builtin function, which is equal only to itself and copies of it with other metadata.
+func NewFn(fn func([]MalType) (MalType, error)) MalFn {
+	return MalFn{fn: fn, id: new(fnID)}
+}
+
 func (MalFn) String() string {
 	return "#<function>"
 }
@@ -367,6 +1007,11 @@ func (mf MalFunc) Fn() func([]MalType) (MalType, error) {
 	}
 }
 
+// Params returns the parameters of the function as written in its fn*.
+func (mf MalFunc) Params() []MalType {
+	return mf.binds
+}
+
 func (mf *MalFunc) IsMacro() bool {
 	return mf.isMacro
 }
@@ -409,104 +1054,33 @@ func IsMacro(val MalType) bool {
 }
 
 func GetMeta(val MalType) MalType {
-	switch val := val.(type) {
-	case MalError:
-		return WrapNil(val.Meta)
-	case MalList:
-		return WrapNil(val.Meta)
-	case MalMap:
-		return WrapNil(val.Meta)
-	case *MalAtom:
-		return WrapNil(val.meta)
-	case MalSymbol:
-		return WrapNil(val.Meta)
-	case MalString:
-		return WrapNil(val.Meta)
-	case MalKeyword:
-		return WrapNil(val.Meta)
-	case MalInt:
-		return WrapNil(val.Meta)
-	case MalBool:
-		return WrapNil(val.Meta)
-	case MalFn:
-		return WrapNil(val.meta)
-	case MalFunc:
-		return WrapNil(val.meta)
-	default:
-		return MalNil{}
+	if mv, ok := val.(MalValue); ok {
+		return WrapNil(mv.Metadata())
 	}
+	return MalNil{}
 }
 
 func WithMeta(val MalType, meta MalType) (MalType, error) {
 	switch val := val.(type) {
-	case MalError:
-		return MalError{Value: val.Value, Meta: meta}, nil
-	case MalList:
-		list := val.New(val.Value)
-		list.Meta = meta
-		return list, nil
-	case MalMap:
-		m := CopyMap(val)
-		m.Meta = meta
-		return m, nil
-	case *MalAtom:
-		return val.WithMeta(meta), nil
-	case MalSymbol:
-		return MalSymbol{Value: val.Value, Meta: meta}, nil
-	case MalString:
-		return MalString{Value: val.Value, Meta: meta}, nil
-	case MalKeyword:
-		return MalKeyword{Value: val.Value, Meta: meta}, nil
-	case MalInt:
-		return MalInt{Value: val.Value, Meta: meta}, nil
-	case MalBool:
-		return MalBool{Value: val.Value, Meta: meta}, nil
-	case MalFn:
-		return MalFn{fn: val.fn, meta: meta}, nil
-	case MalFunc:
-		return MalFunc{eval: val.eval, binds: val.binds, expr: val.expr, env: val.env, meta: meta, isMacro: val.isMacro}, nil
+	case MalValue:
+		return val.WithMetadata(meta)
 	case func([]MalType) (MalType, error):
-		return MalFn{fn: val, meta: meta}, nil
+		fn := NewFn(val)
+		fn.meta = meta
+		return fn, nil
 	default:
 		return RaiseTypeError("MalType", val)
 	}
 }
 
 func TypeName(val MalType) string {
-	if val == nil {
-		return "go-nil"
-	}
 	switch val := val.(type) {
-	case MalError:
-		return "error"
-	case MalList:
-		if IsList(val) {
-			return "list"
-		}
-		return "vector"
-	case MalMap:
-		return "hash-map"
-	case *MalAtom:
-		return "atom"
-	case MalSymbol:
-		return "symbol"
-	case MalString:
-		return "string"
-	case MalKeyword:
-		return "keyword"
-	case MalInt:
-		return "number"
-	case MalBool:
-		return "bool"
-	case MalNil:
-		return "nil"
-	case MalFn, func([]MalType) (MalType, error):
+	case nil:
+		return "go-nil"
+	case MalValue:
+		return val.TypeName()
+	case func([]MalType) (MalType, error):
 		return "builtin-function"
-	case MalFunc:
-		if val.isMacro {
-			return "macro"
-		}
-		return "function"
 	default:
 		return "unknown!"
 	}
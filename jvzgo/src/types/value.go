@@ -0,0 +1,664 @@
+package types
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MalValue is implemented by every value type, including ones defined outside this package, so that printing,
+// equality, hash, metadata and type-of work for a new type without changes to the interpreter.
+//
+// Hash-maps are Go maps, so keys of a comparable type are compared with == rather than Equals, which == should
+// agree with. Keys Go cannot compare are found by Hash and Equals instead (see MapKey).
+type MalValue interface {
+	String() string
+	// Print returns the printed form of the value, readably as pr-str prints it or as str does otherwise.
+	Print(readably bool) string
+	// Equals reports whether the value is equal to other by the rules of =.
+	Equals(other MalType) bool
+	// Hash returns a hash which is the same for all values which are equal, as the hash builtin does.
+	Hash() uint64
+	Metadata() MalType
+	// WithMetadata returns a copy of the value with the given metadata.
+	WithMetadata(meta MalType) (MalType, error)
+	TypeName() string
+}
+
+// Print prints any value, with builtin functions which are plain Go funcs printed as functions.
+func Print(val MalType, readably bool) string {
+	switch val := val.(type) {
+	case MalValue:
+		return val.Print(readably)
+	case func([]MalType) (MalType, error):
+		return "#<function>"
+	default:
+		return fmt.Sprintf("#<unknown: %v>", val)
+	}
+}
+
+// Equal compares any two values with =. A Go func has no identity until NewFn gives it one, so it is not equal
+// to anything.
+func Equal(a, b MalType) bool {
+	switch a := a.(type) {
+	case MalValue:
+		return a.Equals(b)
+	case func([]MalType) (MalType, error):
+		return false
+	default:
+		if a != nil && !reflect.ValueOf(a).Comparable() {
+			return reflect.DeepEqual(a, b)
+		}
+		return a == b
+	}
+}
+
+// Hash hashes any value consistently with Equal.
+func Hash(val MalType) uint64 {
+	switch val := val.(type) {
+	case MalValue:
+		return val.Hash()
+	case func([]MalType) (MalType, error):
+		return hashPointer(val)
+	default:
+		return hashString(fmt.Sprintf("%T %v", val, val))
+	}
+}
+
+func hashString(str string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(str))
+	return h.Sum64()
+}
+
+func hashPointer(val interface{}) uint64 {
+	return uint64(reflect.ValueOf(val).Pointer())
+}
+
+// hashSeq hashes the elements of a sequence. Lists, vectors and lazy seqs with equal elements hash the same.
+func hashSeq(vals []MalType) uint64 {
+	h := uint64(17)
+	for _, val := range vals {
+		h = h*31 + Hash(val)
+	}
+	return h
+}
+
+// noMeta is the WithMetadata of types which do not support metadata.
+func noMeta(val MalType) (MalType, error) {
+	return RaiseTypeError("MalType", val)
+}
+
+func joinStrings(strs []string, start, end string) string {
+	if len(strs) == 0 {
+		return start + end
+	} else {
+		return start + strings.Join(strs, " ") + end
+	}
+}
+
+func (e MalError) String() string {
+	return fmt.Sprint(e.Value)
+}
+
+func (e MalError) Print(readably bool) string {
+	return Print(e.Value, readably)
+}
+
+func (e MalError) Equals(other MalType) bool {
+	b, ok := other.(MalError)
+	return ok && Equal(e.Value, b.Value)
+}
+
+func (e MalError) Hash() uint64 {
+	return Hash(e.Value)
+}
+
+func (e MalError) Metadata() MalType {
+	return WrapNil(e.Meta)
+}
+
+func (e MalError) WithMetadata(meta MalType) (MalType, error) {
+	return MalError{Value: e.Value, Meta: meta}, nil
+}
+
+func (MalError) TypeName() string {
+	return "error"
+}
+
+func (ml MalList) Print(readably bool) string {
+	strs := make([]string, len(ml.Value))
+	for i, val := range ml.Value {
+		strs[i] = Print(val, readably)
+	}
+	return ml.Surround(strings.Join(strs, " "))
+}
+
+func (ml MalList) Equals(other MalType) bool {
+	bs, err := GetSlice(other)
+	if err != nil || len(ml.Value) != len(bs) {
+		return false
+	}
+	for i := range ml.Value {
+		if !Equal(ml.Value[i], bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ml MalList) Hash() uint64 {
+	return hashSeq(ml.Value)
+}
+
+func (ml MalList) Metadata() MalType {
+	return WrapNil(ml.Meta)
+}
+
+func (ml MalList) WithMetadata(meta MalType) (MalType, error) {
+	list := ml.New(ml.Value)
+	list.Meta = meta
+	return list, nil
+}
+
+func (ml MalList) TypeName() string {
+	if IsList(ml) {
+		return "list"
+	}
+	return "vector"
+}
+
+func (ls *MalLazySeq) Print(readably bool) string {
+	vals, err := ls.ToSlice()
+	if err != nil {
+		return fmt.Sprintf("#<lazy-seq error: %v>", err)
+	}
+	return NewList(vals).Print(readably)
+}
+
+func (ls *MalLazySeq) Equals(other MalType) bool {
+	vals, err := ls.ToSlice()
+	return err == nil && NewList(vals).Equals(other)
+}
+
+func (ls *MalLazySeq) Hash() uint64 {
+	vals, _ := ls.ToSlice()
+	return hashSeq(vals)
+}
+
+func (ls *MalLazySeq) WithMetadata(meta MalType) (MalType, error) {
+	return ls.WithMeta(meta), nil
+}
+
+func (*MalLazySeq) TypeName() string {
+	return "lazy-seq"
+}
+
+func (mm MalMap) String() string {
+	return mm.Print(false)
+}
+
+func (mm MalMap) Print(readably bool) string {
+	strs := make([]string, 0, len(mm.Value)*2)
+	for k, v := range mm.Value {
+		strs = append(strs, Print(KeyValue(k), readably), Print(v, readably))
+	}
+	return joinStrings(strs, "{", "}")
+}
+
+func (mm MalMap) Equals(other MalType) bool {
+	b, ok := other.(MalMap)
+	if !ok || len(mm.Value) != len(b.Value) {
+		return false
+	}
+	for key, x := range mm.Value {
+		y, ok := b.Value[key]
+		if !ok || !Equal(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+func (mm MalMap) Hash() uint64 {
+	// entries are summed so that the hash does not depend on the iteration order
+	var h uint64
+	for k, v := range mm.Value {
+		h += Hash(KeyValue(k))*31 ^ Hash(v)
+	}
+	return h
+}
+
+func (mm MalMap) Metadata() MalType {
+	return WrapNil(mm.Meta)
+}
+
+func (mm MalMap) WithMetadata(meta MalType) (MalType, error) {
+	m := CopyMap(mm)
+	m.Meta = meta
+	return m, nil
+}
+
+func (MalMap) TypeName() string {
+	return "hash-map"
+}
+
+func (ma *MalAtom) Print(readably bool) string {
+	return "(atom " + Print(ma.Value(), readably) + ")"
+}
+
+func (ma *MalAtom) Equals(other MalType) bool {
+	b, ok := other.(*MalAtom)
+	return ok && Equal(ma.Value(), b.Value())
+}
+
+func (ma *MalAtom) Hash() uint64 {
+	return Hash(ma.Value())
+}
+
+func (ma *MalAtom) WithMetadata(meta MalType) (MalType, error) {
+	return ma.WithMeta(meta), nil
+}
+
+func (*MalAtom) TypeName() string {
+	return "atom"
+}
+
+func (mf *MalFuture) String() string {
+	return mf.Print(false)
+}
+
+func (mf *MalFuture) Print(readably bool) string {
+	name := mf.TypeName()
+	switch {
+	case mf.IsCancelled():
+		return "#<" + name + " cancelled>"
+	case mf.IsDone():
+		val, err := mf.Wait()
+		if err != nil {
+			return "#<" + name + " failed>"
+		}
+		return "#<" + name + " " + Print(val, readably) + ">"
+	default:
+		return "#<" + name + " pending>"
+	}
+}
+
+func (mf *MalFuture) Equals(other MalType) bool {
+	return mf == other
+}
+
+func (mf *MalFuture) Hash() uint64 {
+	return hashPointer(mf)
+}
+
+func (*MalFuture) Metadata() MalType {
+	return MalNil{}
+}
+
+func (mf *MalFuture) WithMetadata(meta MalType) (MalType, error) {
+	return noMeta(mf)
+}
+
+func (mf *MalFuture) TypeName() string {
+	if mf.promise {
+		return "promise"
+	}
+	return "future"
+}
+
+func (mc *MalChan) String() string {
+	return mc.Print(false)
+}
+
+func (mc *MalChan) Print(readably bool) string {
+	if mc.IsClosed() {
+		return "#<chan closed>"
+	}
+	return "#<chan>"
+}
+
+func (mc *MalChan) Equals(other MalType) bool {
+	b, ok := other.(*MalChan)
+	return ok && mc.IsSame(b)
+}
+
+func (mc *MalChan) Hash() uint64 {
+	return hashPointer(mc.chanState)
+}
+
+func (mc *MalChan) WithMetadata(meta MalType) (MalType, error) {
+	return mc.WithMeta(meta), nil
+}
+
+func (*MalChan) TypeName() string {
+	return "chan"
+}
+
+func (ms MalSymbol) Print(readably bool) string {
+	return ms.Value
+}
+
+func (ms MalSymbol) Equals(other MalType) bool {
+	b, ok := other.(MalSymbol)
+	return ok && ms.Value == b.Value
+}
+
+func (ms MalSymbol) Hash() uint64 {
+	return hashString("symbol " + ms.Value)
+}
+
+func (ms MalSymbol) Metadata() MalType {
+	return WrapNil(ms.Meta)
+}
+
+func (ms MalSymbol) WithMetadata(meta MalType) (MalType, error) {
+	return MalSymbol{Value: ms.Value, Meta: meta}, nil
+}
+
+func (MalSymbol) TypeName() string {
+	return "symbol"
+}
+
+func (ms MalString) Print(readably bool) string {
+	if readably {
+		return strconv.Quote(ms.Value)
+	}
+	return ms.Value
+}
+
+func (ms MalString) Equals(other MalType) bool {
+	b, ok := other.(MalString)
+	return ok && ms.Value == b.Value
+}
+
+func (ms MalString) Hash() uint64 {
+	return hashString("string " + ms.Value)
+}
+
+func (ms MalString) Metadata() MalType {
+	return WrapNil(ms.Meta)
+}
+
+func (ms MalString) WithMetadata(meta MalType) (MalType, error) {
+	return MalString{Value: ms.Value, Meta: meta}, nil
+}
+
+func (MalString) TypeName() string {
+	return "string"
+}
+
+func (mr MalRegex) Print(readably bool) string {
+	if readably {
+		return `#"` + mr.Value.String() + `"`
+	}
+	return mr.Value.String()
+}
+
+func (mr MalRegex) Equals(other MalType) bool {
+	b, ok := other.(MalRegex)
+	return ok && mr.Value.String() == b.Value.String()
+}
+
+func (mr MalRegex) Hash() uint64 {
+	return hashString("regex " + mr.Value.String())
+}
+
+func (mr MalRegex) Metadata() MalType {
+	return WrapNil(mr.Meta)
+}
+
+func (mr MalRegex) WithMetadata(meta MalType) (MalType, error) {
+	return MalRegex{Value: mr.Value, whole: mr.whole, Meta: meta}, nil
+}
+
+func (MalRegex) TypeName() string {
+	return "regex"
+}
+
+func (mc MalChar) Print(readably bool) string {
+	if !readably {
+		return string(mc.Value)
+	}
+	switch mc.Value {
+	case '\n':
+		return `\newline`
+	case ' ':
+		return `\space`
+	case '\t':
+		return `\tab`
+	case '\r':
+		return `\return`
+	case '\b':
+		return `\backspace`
+	case '\f':
+		return `\formfeed`
+	}
+	if !unicode.IsPrint(mc.Value) {
+		return fmt.Sprintf(`\u%04x`, mc.Value)
+	}
+	return `\` + string(mc.Value)
+}
+
+func (mc MalChar) Equals(other MalType) bool {
+	b, ok := other.(MalChar)
+	return ok && mc.Value == b.Value
+}
+
+func (mc MalChar) Hash() uint64 {
+	return hashString("char " + string(mc.Value))
+}
+
+func (mc MalChar) Metadata() MalType {
+	return WrapNil(mc.Meta)
+}
+
+func (mc MalChar) WithMetadata(meta MalType) (MalType, error) {
+	return MalChar{Value: mc.Value, Meta: meta}, nil
+}
+
+func (MalChar) TypeName() string {
+	return "char"
+}
+
+func (mg MalGo) Print(readably bool) string {
+	return fmt.Sprintf("#<%T %v>", mg.Value, mg.Value)
+}
+
+func (mg MalGo) Equals(other MalType) bool {
+	b, ok := other.(MalGo)
+	if !ok {
+		return false
+	}
+	if mg.Value != nil && !reflect.TypeOf(mg.Value).Comparable() {
+		return reflect.DeepEqual(mg.Value, b.Value)
+	}
+	return mg.Value == b.Value
+}
+
+func (mg MalGo) Hash() uint64 {
+	return hashString(fmt.Sprintf("go %T %v", mg.Value, mg.Value))
+}
+
+func (mg MalGo) Metadata() MalType {
+	return WrapNil(mg.Meta)
+}
+
+func (mg MalGo) WithMetadata(meta MalType) (MalType, error) {
+	return MalGo{Value: mg.Value, Meta: meta}, nil
+}
+
+func (MalGo) TypeName() string {
+	return "go-value"
+}
+
+func (mk MalKeyword) Print(readably bool) string {
+	return ":" + mk.Value
+}
+
+func (mk MalKeyword) Equals(other MalType) bool {
+	b, ok := other.(MalKeyword)
+	return ok && mk.Value == b.Value
+}
+
+func (mk MalKeyword) Hash() uint64 {
+	return hashString("keyword " + mk.Value)
+}
+
+func (mk MalKeyword) Metadata() MalType {
+	return WrapNil(mk.Meta)
+}
+
+func (mk MalKeyword) WithMetadata(meta MalType) (MalType, error) {
+	return MalKeyword{Value: mk.Value, Meta: meta}, nil
+}
+
+func (MalKeyword) TypeName() string {
+	return "keyword"
+}
+
+func (mi MalInt) Print(readably bool) string {
+	return strconv.Itoa(mi.Value)
+}
+
+func (mi MalInt) Equals(other MalType) bool {
+	b, ok := other.(MalInt)
+	return ok && mi.Value == b.Value
+}
+
+func (mi MalInt) Hash() uint64 {
+	return uint64(mi.Value)
+}
+
+func (mi MalInt) Metadata() MalType {
+	return WrapNil(mi.Meta)
+}
+
+func (mi MalInt) WithMetadata(meta MalType) (MalType, error) {
+	return MalInt{Value: mi.Value, Meta: meta}, nil
+}
+
+func (MalInt) TypeName() string {
+	return "number"
+}
+
+func (mb MalBool) Print(readably bool) string {
+	return mb.String()
+}
+
+func (mb MalBool) Equals(other MalType) bool {
+	b, ok := other.(MalBool)
+	return ok && mb.Value == b.Value
+}
+
+func (mb MalBool) Hash() uint64 {
+	return hashString(mb.String())
+}
+
+func (mb MalBool) Metadata() MalType {
+	return WrapNil(mb.Meta)
+}
+
+func (mb MalBool) WithMetadata(meta MalType) (MalType, error) {
+	return MalBool{Value: mb.Value, Meta: meta}, nil
+}
+
+func (MalBool) TypeName() string {
+	return "bool"
+}
+
+func (MalNil) Print(readably bool) string {
+	return "nil"
+}
+
+func (MalNil) Equals(other MalType) bool {
+	return IsNil(other)
+}
+
+func (MalNil) Hash() uint64 {
+	return 0
+}
+
+func (MalNil) Metadata() MalType {
+	return MalNil{}
+}
+
+func (mn MalNil) WithMetadata(meta MalType) (MalType, error) {
+	return noMeta(mn)
+}
+
+func (MalNil) TypeName() string {
+	return "nil"
+}
+
+func (MalFn) Print(readably bool) string {
+	return "#<function>"
+}
+
+func (mf MalFn) Equals(other MalType) bool {
+	b, ok := other.(MalFn)
+	return ok && mf.id == b.id
+}
+
+func (mf MalFn) Hash() uint64 {
+	return hashPointer(mf.id)
+}
+
+func (mf MalFn) Metadata() MalType {
+	return WrapNil(mf.meta)
+}
+
+func (mf MalFn) WithMetadata(meta MalType) (MalType, error) {
+	return MalFn{fn: mf.fn, id: mf.id, meta: meta}, nil
+}
+
+func (MalFn) TypeName() string {
+	return "builtin-function"
+}
+
+func (mf MalFunc) Print(readably bool) string {
+	return mf.String()
+}
+
+// Equals reports whether other is the same closure: one created by the same fn* form in the same environment.
+func (mf MalFunc) Equals(other MalType) bool {
+	b, ok := other.(MalFunc)
+	return ok && mf.env == b.env && mf.isMacro == b.isMacro && sameSlice(mf.binds, b.binds) &&
+		sameForm(mf.expr, b.expr)
+}
+
+// sameSlice reports whether two slices share their elements, as the parts of a form read once do.
+func sameSlice(a, b []MalType) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+func sameForm(a, b MalType) bool {
+	al, ok := a.(MalList)
+	bl, ok2 := b.(MalList)
+	if ok && ok2 {
+		return sameSlice(al.Value, bl.Value)
+	}
+	return Equal(a, b)
+}
+
+func (mf MalFunc) Hash() uint64 {
+	return hashSeq(mf.binds)*31 + Hash(mf.expr)
+}
+
+func (mf MalFunc) Metadata() MalType {
+	return WrapNil(mf.meta)
+}
+
+func (mf MalFunc) WithMetadata(meta MalType) (MalType, error) {
+	return MalFunc{eval: mf.eval, binds: mf.binds, expr: mf.expr, env: mf.env, meta: meta, isMacro: mf.isMacro}, nil
+}
+
+func (mf MalFunc) TypeName() string {
+	if mf.isMacro {
+		return "macro"
+	}
+	return "function"
+}
@@ -0,0 +1,51 @@
+package types
+
+// MalVar refers to a binding, as made by the var special form or #'name. Its metadata is that of the binding,
+// such as the docstring and parameters recorded by def!, and deref gives the value currently bound.
+type MalVar struct {
+	Name string
+	env  EnvType
+}
+
+// NewVar refers to the binding of name in env.
+func NewVar(name string, env EnvType) MalVar {
+	return MalVar{Name: name, env: env}
+}
+
+func (mv MalVar) Deref() (MalType, error) {
+	return mv.env.Get(mv.Name)
+}
+
+func (mv MalVar) String() string {
+	return mv.Print(false)
+}
+
+func (mv MalVar) Print(bool) string {
+	return "#'" + mv.Name
+}
+
+func (mv MalVar) Equals(other MalType) bool {
+	b, ok := other.(MalVar)
+	return ok && mv.Name == b.Name && mv.env == b.env
+}
+
+func (mv MalVar) Hash() uint64 {
+	return hashString(mv.Name)
+}
+
+func (mv MalVar) Metadata() MalType {
+	return mv.env.Meta(mv.Name)
+}
+
+func (mv MalVar) WithMetadata(MalType) (MalType, error) {
+	return noMeta(mv)
+}
+
+func (MalVar) TypeName() string {
+	return "var"
+}
+
+func IsVar(val MalType) bool {
+	_, ok := val.(MalVar)
+	return ok
+}